@@ -2,11 +2,20 @@ package main
 
 import (
 	"chating_service/internal/config"
+	"chating_service/internal/constants"
 	"chating_service/internal/controller"
 	"chating_service/internal/db"
 	"chating_service/internal/router"
+	"chating_service/internal/search"
+	"chating_service/internal/storage"
+	"context"
+	"errors"
+	"expvar"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,16 +33,90 @@ func main() {
 	authMiddleware := controller.InitJwt(&config)
 
 	engine := gin.New()
+	// the rate limits key on the client address, only trusted proxies may forward it
+	err = engine.SetTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		panic(err)
+	}
 	setupLogConfig(&config)
 	db.InitDbConnection(&config)
 	db.InitRedisConnection(&config)
+	err = storage.InitStorage(config.Storage)
+	if err != nil {
+		panic(err)
+	}
+	err = search.InitSearcher(config.Search)
+	if err != nil {
+		panic(err)
+	}
+
+	hub := router.InitRoute(engine, authMiddleware)
 
-	router.InitRoute(engine, authMiddleware)
+	server := &http.Server{Addr: ":8080", Handler: engine}
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	metricsAddr := constants.MetricsAddr
+	if config.Server.MetricsAddr != "" {
+		metricsAddr = config.Server.MetricsAddr
+	}
+	metricsServer := startMetricsServer(metricsAddr)
 
-	err = engine.Run(":8080")
+	signalCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-signalCtx.Done()
+
+	timeout := constants.ShutdownTimeout
+	if config.Server.ShutdownTimeoutSeconds > 0 {
+		timeout = time.Duration(config.Server.ShutdownTimeoutSeconds) * time.Second
+	}
+	shutdown(server, hub, timeout)
+	metricsServer.Close()
+}
+
+// startMetricsServer serves the expvars on their own address, kept off the public api
+func startMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	metricsServer := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		err := metricsServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Err(err).Msg("Failed to serve metrics")
+		}
+	}()
+	return metricsServer
+}
+
+// shutdown stops accepting requests, drains the http requests and the websocket connections,
+// then closes mysql and redis. Whatever is still running when the timeout ends is cut.
+func shutdown(server *http.Server, hub *controller.Hub, timeout time.Duration) {
+	log.Info().Msgf("Shutting down within %v", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// websocket connections are hijacked, the http server does not wait for them.
+	// Both drain at the same time so neither uses up the deadline of the other.
+	hubDrained := make(chan error, 1)
+	go func() {
+		hubDrained <- hub.Shutdown(ctx)
+	}()
+	err := server.Shutdown(ctx)
 	if err != nil {
-		panic(err)
+		log.Err(err).Msg("Failed to drain http requests")
 	}
+	err = <-hubDrained
+	if err != nil {
+		log.Err(err).Msg("Failed to drain websocket connections")
+	}
+
+	db.CloseDbConnection()
+	db.CloseRedisConnection()
+	log.Info().Msg("Shut down")
 }
 
 func setupLogConfig(config *config.AppConfig) {
@@ -45,12 +45,88 @@ type RedisConfig struct {
 	Password string `mapstructure:"password"`
 }
 
+// PushConfig is the push gateway offline members are notified through.
+// Push notifications are disabled while Endpoint is empty.
+type PushConfig struct {
+	Endpoint       string `mapstructure:"endpoint"`
+	ApiKey         string `mapstructure:"api-key"`
+	TimeoutSeconds int    `mapstructure:"timeout-seconds"`
+}
+
+// StorageConfig selects the attachment storage: "local" (the default) or "s3"
+type StorageConfig struct {
+	Driver string             `mapstructure:"driver"`
+	Local  LocalStorageConfig `mapstructure:"local"`
+	S3     S3StorageConfig    `mapstructure:"s3"`
+}
+
+// LocalStorageConfig keeps attachments on the disk of the server, which serves them itself
+type LocalStorageConfig struct {
+	Path    string `mapstructure:"path"`
+	BaseUrl string `mapstructure:"base-url"` // url the clients reach this server at
+	SignKey string `mapstructure:"sign-key"`
+}
+
+// S3StorageConfig keeps attachments in an S3 compatible bucket, such as AWS S3 or MinIO
+type S3StorageConfig struct {
+	Endpoint     string `mapstructure:"endpoint"`
+	Region       string `mapstructure:"region"`
+	Bucket       string `mapstructure:"bucket"`
+	AccessKey    string `mapstructure:"access-key"`
+	SecretKey    string `mapstructure:"secret-key"`
+	UsePathStyle bool   `mapstructure:"use-path-style"` // required by MinIO
+}
+
+// SearchConfig selects the message search: "mysql" (the default) or "memory"
+type SearchConfig struct {
+	Driver string `mapstructure:"driver"`
+}
+
+// ServerConfig is how the server stops. On SIGTERM it drains the websocket connections and
+// the http requests for at most ShutdownTimeoutSeconds before it exits.
+// TrustedProxies are the addresses or CIDRs of the proxies whose X-Forwarded-For header gives
+// the client address. Without them the client address is the remote address of the request.
+// MetricsAddr is where /debug/vars is served apart from the api, it must not be reachable
+// from the public network.
+type ServerConfig struct {
+	ShutdownTimeoutSeconds int      `mapstructure:"shutdown-timeout-seconds"`
+	TrustedProxies         []string `mapstructure:"trusted-proxies"`
+	MetricsAddr            string   `mapstructure:"metrics-addr"`
+}
+
+// WebsocketConfig tunes the websocket connections, zero values keep the defaults
+type WebsocketConfig struct {
+	SendBufferSize int `mapstructure:"send-buffer-size"` // frames queued per connection
+	// SlowConsumer is what happens to a frame for a connection whose queue is full:
+	// "disconnect" (the default), "drop-oldest" or "drop-newest"
+	SlowConsumer          string `mapstructure:"slow-consumer"`
+	SlowConsumerCloseCode int    `mapstructure:"slow-consumer-close-code"`
+
+	// the server pings every connection and closes it when no pong came back within PongWaitSeconds
+	PingIntervalSeconds int `mapstructure:"ping-interval-seconds"`
+	PongWaitSeconds     int `mapstructure:"pong-wait-seconds"`
+	WriteWaitSeconds    int `mapstructure:"write-wait-seconds"`
+	// IdleTimeoutSeconds closes connections the client sent no frame on for that long,
+	// pongs aside. Negative disables it.
+	IdleTimeoutSeconds int   `mapstructure:"idle-timeout-seconds"`
+	MaxMessageSize     int64 `mapstructure:"max-message-size"` // bytes, read limit above the 16 KiB envelope size
+	// AllowedOrigins are the origins, e.g. "https://chat.example.com", of the pages that may open
+	// a websocket authenticated by the token cookie. The origin of the server itself is always allowed.
+	AllowedOrigins []string `mapstructure:"allowed-origins"`
+}
+
 type AppConfig struct {
-	Mongo MongoConfig `mapstructure:"mongo"`
-	Jwt   JwtConfig   `mapstructure:"jwt"`
-	Log   LogConfig   `mapstructure:"log"`
-	Rdb   RdbConfig   `mapstructure:"rdb"`
-	Redis RedisConfig `mapstructure:"redis"`
+	Mongo   MongoConfig   `mapstructure:"mongo"`
+	Jwt     JwtConfig     `mapstructure:"jwt"`
+	Log     LogConfig     `mapstructure:"log"`
+	Rdb     RdbConfig     `mapstructure:"rdb"`
+	Redis   RedisConfig   `mapstructure:"redis"`
+	Push    PushConfig    `mapstructure:"push"`
+	Storage StorageConfig `mapstructure:"storage"`
+	Search  SearchConfig  `mapstructure:"search"`
+
+	Server    ServerConfig    `mapstructure:"server"`
+	Websocket WebsocketConfig `mapstructure:"websocket"`
 }
 
 var appConfig AppConfig
@@ -75,6 +151,7 @@ func LoadConfig() (AppConfig, error) {
 		return config, err
 	}
 
+	appConfig = config
 	return config, nil
 }
 
@@ -1,5 +1,7 @@
 package constants
 
+import "time"
+
 const (
 	ServerName = "chating_service"
 )
@@ -34,9 +36,160 @@ const (
 
 	PasswordMinLength = 8
 	PasswordMaxLength = 20
+
+	// 채팅방 멤버 권한
+	RoomRoleMember    = 1
+	RoomRoleModerator = 2
+	RoomRoleOwner     = 3
+
+	// 채팅방
+	MaxRoomNameLength        = 50
+	MaxRoomDescriptionLength = 500
+
+	// 채팅 메시지
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 100
+
+	MaxEmojiLength = 32 // bytes
+
+	MaxMentionsPerMessage = 20
+)
+
+// attachments
+const (
+	MaxAttachmentSize           = 20 * 1024 * 1024 // bytes
+	MaxAttachmentsPerMessage    = 10
+	MaxAttachmentFileNameLength = 255
+	AttachmentUrlExpire         = 15 * time.Minute
+)
+
+// AttachmentExtensions are the file types that can be attached to a message
+var AttachmentExtensions = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".zip":  "application/zip",
+	".mp4":  "video/mp4",
+}
+
+// push notifications
+const (
+	PushQueueSize     = 1024
+	PushTimeout       = 10 * time.Second
+	PushPreviewLength = 100 // characters of the message body
+)
+
+// @room and @here mention everyone in the room and everyone present in it
+const (
+	MentionRoom = "room"
+	MentionHere = "here"
+)
+
+// websocket
+const (
+	WebsocketTokenProtocol = "access_token"
+
+	MaxEnvelopeSize      = 16 * 1024 // bytes
+	MaxMessageTextLength = 4000      // characters
+	// default read limit of a connection. Frames over MaxEnvelopeSize up to it are answered with
+	// an error frame, larger ones close the connection.
+	MaxFrameSize = 4 * MaxEnvelopeSize
+
+	// rooms and accounts are spread over the hub shards, each fanning out on its own goroutine
+	HubShardCount  = 64
+	ShardInboxSize = 1024 // broadcasts queued per shard
+
+	SendBufferSize = 256 // frames queued per connection
+	// a resume replays at most MaxResumeMessages and keeps back at most MaxHeldFrames
+	// live frames meanwhile, together they must fit in the send buffer
+	MaxResumeMessages = 200
+	MaxHeldFrames     = 50
+	MinSendBufferSize = MaxResumeMessages + MaxHeldFrames + 1
+
+	// what happens to a frame for a connection whose send buffer is full
+	SlowConsumerDisconnect = "disconnect"
+	SlowConsumerDropOldest = "drop-oldest"
+	SlowConsumerDropNewest = "drop-newest"
+	SlowConsumerCloseCode  = 1013 // try again later
+	CloseFrameTimeout      = time.Second
+
+	// heartbeat defaults, pings go out before the pong wait of the last one ends
+	PongWait     = 60 * time.Second
+	PingInterval = PongWait * 9 / 10
+	WriteWait    = 10 * time.Second
+	IdleTimeout  = 30 * time.Minute
+
+	// connections closed by a shutdown are told to reconnect after a random delay up to
+	// ReconnectJitter, so they do not all come back at once
+	ReconnectJitter = 5 * time.Second
+	ShutdownTimeout = 30 * time.Second
+
+	MetricsAddr = "127.0.0.1:6060" // expvars are only served on the loopback by default
+
+	// message frames a connection may leave unacknowledged, older ones are forgotten first
+	MaxUnackedMessages = 500
+	MaxAckMessageIds   = 100 // message ids per ack frame
+	UnackedExpire      = 24 * time.Hour
+)
+
+// rate limits are token buckets holding up to Burst tokens, refilled with one every Refill
+const (
+	ConnectionFrameBurst  = 30 // frames of a connection, typing and acks aside
+	ConnectionFrameRefill = 200 * time.Millisecond
+	TypingFrameBurst      = 10 // typing indicators of a connection, starts and stops
+	TypingFrameRefill     = time.Second
+	AccountMessageBurst   = 20 // messages of an account in every room
+	AccountMessageRefill  = 500 * time.Millisecond
+	RoomMessageBurst      = 100 // messages of a room from every account
+	RoomMessageRefill     = 20 * time.Millisecond
+
+	LoginBurst         = 5 // login attempts per client address
+	LoginRefill        = time.Minute
+	LoginUserBurst     = 5 // login attempts per user id from every address
+	LoginUserRefill    = time.Minute
+	RefreshTokenBurst  = 10 // token refreshes per client address
+	RefreshTokenRefill = 30 * time.Second
+
+	// an account limited more than MaxRateLimitStrikes times within the window is disconnected
+	MaxRateLimitStrikes   = 10
+	RateLimitStrikeWindow = time.Minute
+)
+
+// presence
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+
+	PresenceTtl = 90 * time.Second
+	// connections refresh their presence at most once per interval
+	PresenceRefreshInterval = 15 * time.Second
+)
+
+// typing indicator
+const (
+	TypingThrottle = 2 * time.Second // one typing event per user and room per throttle
+	TypingExpire   = 5 * time.Second
 )
 
 // redis key
 const (
 	RefreshTokenKey = "refresh_"
+
+	PresenceCountKey  = "presence_count_"  // + roomId_accountId : number of live connections
+	PresenceStatusKey = "presence_status_" // + roomId_accountId : online or away
+	PresenceRoomKey   = "presence_room_"   // + roomId : sorted set of account ids scored by expiry
+
+	PresenceAccountKey = "presence_account_" // + accountId : number of live connections to any room
+
+	TypingKey = "typing_" // + roomId_accountId : set while the typing event is throttled
+
+	UnackedKey = "unacked_" // + roomId_accountId : set of message ids sent to a closed connection and never acked
+
+	RateLimitKey       = "rate_"        // + bucket name_id : token bucket hash of tokens and refill time
+	RateLimitStrikeKey = "rate_strike_" // + accountId : number of limited frames in the strike window
 )
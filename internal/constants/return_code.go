@@ -46,6 +46,7 @@ const (
 	ExceedMaxLength    = 2009
 	UnremovableItem    = 2010
 	UnupdatableItem    = 2011
+	RateLimited        = 2012
 
 	// ota
 	InvalidProductId  = 2101
@@ -59,6 +60,11 @@ const (
 	InvalidFileExtension = 4002
 	InvalidFileCheckSum  = 4003
 
+	// 채팅방
+	NotRoomMember    = 5001
+	PermissionDenied = 5002
+	AlreadyMember    = 5003
+
 	// 제품
 	InvalidProductManufacturer = 7001
 	InvalidSoftwareProfile     = 7002
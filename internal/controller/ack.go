@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// keepUnacked stores the messages a closed connection never acknowledged,
+// they are sent again on the next connection of the account to the room
+func keepUnacked(subscription Subscription, messageIds []int64) {
+	localCtx := newHubLocalCtx(subscription.sender.AccountId)
+	err := service.KeepUnackedMessages(localCtx, subscription.roomId, messageIds)
+	if err != nil {
+		log.Err(err).Msgf("Failed to keep unacked messages. room : %s, account : %d", subscription.roomId, subscription.sender.AccountId)
+	}
+}
+
+// redeliver sends a new connection the messages its account did not acknowledge on its
+// previous connections to the room. They wait for an ack again.
+func (h *Hub) redeliver(localCtx *model.LocalCtx, subscription Subscription) error {
+	messages, err := service.GetUnackedMessages(localCtx, subscription.roomId)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+
+	accountIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		accountIds = append(accountIds, message.AccountId)
+	}
+	userIds, err := service.GetUserIds(localCtx, accountIds)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		sender := model.Sender{AccountId: message.AccountId, UserId: userIds[message.AccountId]}
+		out, err := messageEnvelope(sender, message)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		h.deliver(subscription.client, data, message.Id)
+	}
+	return nil
+}
+
+// sendDelivered tells the sender of a message frame that the message is stored
+func (h *Hub) sendDelivered(subscription Subscription, id string, message model.Message) {
+	out, err := newEnvelope(model.EnvelopeTypeDelivered, subscription.roomId, nil, model.DeliveredPayload{MessageId: message.Id, Seq: message.Seq})
+	if err != nil {
+		log.Err(err).Msg("Failed to build delivered frame")
+		return
+	}
+	out.Id = id
+	h.sendEnvelopeTo(subscription, out)
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+	"chating_service/internal/storage"
+)
+
+// CreateAttachment announces an upload and returns the url to upload the file to.
+// The returned object key is then sent in the attachmentKeys of a message.
+func CreateAttachment(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	var form model.NewAttachmentForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	attachment, uploadUrl, err := service.CreateAttachmentUpload(localCtx, ctx.Param("roomId"), form)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	SignedUrlResponse(ctx, attachment.ObjectKey, uploadUrl)
+}
+
+// UploadLocalFile stores a file uploaded to a url signed by the local storage
+func UploadLocalFile(localStorage *storage.LocalStorage, ctx *gin.Context) {
+	objectKey, ok := verifyLocalFileUrl(localStorage, ctx)
+	if !ok {
+		return
+	}
+
+	if ctx.Request.ContentLength > constants.MaxAttachmentSize {
+		FailureResponse(ctx, constants.InvalidFileSize)
+		return
+	}
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, constants.MaxAttachmentSize)
+
+	err := localStorage.Save(objectKey, body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			FailureResponse(ctx, constants.InvalidFileSize)
+			return
+		}
+		log.Error().Msgf("Failed to save uploaded file: %v", err)
+		FailureResponse(ctx, constants.ServerInternalError)
+		return
+	}
+
+	SuccessResponse(ctx)
+}
+
+// DownloadLocalFile serves a file of the local storage to a signed download url
+func DownloadLocalFile(localStorage *storage.LocalStorage, ctx *gin.Context) {
+	objectKey, ok := verifyLocalFileUrl(localStorage, ctx)
+	if !ok {
+		return
+	}
+
+	file, err := localStorage.Open(objectKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Msgf("Failed to open file: %v", err)
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		ctx.Status(http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(ctx.Writer, ctx.Request, info.Name(), info.ModTime(), file)
+}
+
+func verifyLocalFileUrl(localStorage *storage.LocalStorage, ctx *gin.Context) (string, bool) {
+	objectKey := strings.TrimPrefix(ctx.Param("objectKey"), "/")
+	err := localStorage.VerifySignature(ctx.Request.Method, objectKey, ctx.Query("expires"), ctx.Query("signature"))
+	if err != nil {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return objectKey, true
+}
@@ -39,12 +39,8 @@ func InitJwt(appConfig *config.AppConfig) gin.HandlerFunc {
 		}
 
 		tokenString := authHeader[len("Bearer "):]
-		claims := &CustomClaims{}
-
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return AccessSecret, nil
-		})
-		if err != nil || !token.Valid {
+		claims, err := parseAccessToken(tokenString)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
@@ -55,6 +51,23 @@ func InitJwt(appConfig *config.AppConfig) gin.HandlerFunc {
 	}
 }
 
+// parseAccessToken validates an access token and returns its claims
+func parseAccessToken(tokenString string) (*CustomClaims, error) {
+	claims := &CustomClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return AccessSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid || claims.ID <= 0 {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
 func authenticateAccount(localCtx *model.LocalCtx, userId, password string) (model.Account, error) {
 
 	account, err := repo.GetUserAccount(localCtx.RdbCtx, userId)
@@ -99,6 +112,9 @@ func LoginHandler(c *gin.Context, authMiddleware gin.HandlerFunc) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "missing login values"})
 		return
 	}
+	if !allowLoginUser(c, localCtx, loginForm.UserId) {
+		return
+	}
 
 	account, err := authenticateAccount(localCtx, loginForm.UserId, loginForm.Password)
 	if err != nil {
@@ -125,6 +141,14 @@ func LoginHandler(c *gin.Context, authMiddleware gin.HandlerFunc) {
 		return
 	}
 
+	// 푸시 토큰 저장 실패는 로그인을 막지 않는다
+	if loginForm.PushToken != "" {
+		err = repo.UpsertPushToken(localCtx.RdbCtx, account.Id, loginForm.PushToken)
+		if err != nil {
+			log.Error().Msgf("Failed to upsert push token: %v", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"access_token":   accessToken,
 		"refresh_token":  refreshToken,
@@ -4,7 +4,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
 	"chating_service/internal/service"
+	"chating_service/internal/storage"
 )
 
 func GetChatingRoom(ctx *gin.Context) {
@@ -19,3 +22,71 @@ func GetChatingRoom(ctx *gin.Context) {
 
 	ResponseWithData(ctx, chatingRooms)
 }
+
+func CreateChatingRoom(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	var form model.NewChatingRoomForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	chatingRoom, err := service.CreateChatingRoom(localCtx, form)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, chatingRoom)
+}
+
+func UpdateChatingRoom(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+
+	var form model.UpdateChatingRoomForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return
+	}
+
+	chatingRoom, err := service.UpdateChatingRoom(localCtx, roomId, form)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	// an archived room is read only, so its live connections are closed
+	if !chatingRoom.IsUsed {
+		err = hub.closeRoom(roomId)
+		if err != nil {
+			log.Error().Msgf("Failed to close archived room: %v", err)
+		}
+	}
+	ResponseWithData(ctx, chatingRoom)
+}
+
+func DeleteChatingRoom(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+
+	objectKeys, err := service.DeleteChatingRoom(localCtx, roomId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	err = hub.closeRoom(roomId)
+	if err != nil {
+		log.Error().Msgf("Failed to close deleted room: %v", err)
+	}
+	// nothing references the objects anymore, one left behind only takes space
+	for _, objectKey := range objectKeys {
+		err = storage.GetStorage().Delete(ctx, objectKey)
+		if err != nil {
+			log.Error().Msgf("Failed to delete attachment object %s of room %s: %v", objectKey, roomId, err)
+		}
+	}
+	SuccessResponse(ctx)
+}
@@ -0,0 +1,223 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+// client is a registered websocket connection. It owns its outbound queue, drained by
+// writePump and bounded by the queue policy of the hub, along with its resume and ack state.
+// Its lock is never shared with another connection, so a broadcast only ever waits for the
+// connection it is queued to.
+type client struct {
+	conn   *websocket.Conn
+	roomId string
+	sender model.Sender
+	send   chan []byte
+	policy *queuePolicy
+	// deadline is only used by the goroutine serving the connection
+	deadline *connDeadline
+
+	mu     sync.Mutex
+	closed bool // the outbound queue is closed
+	// closeFrame is the payload of the close frame writePump sends after the queue, set
+	// before the queue is closed
+	closeFrame []byte
+	holding    bool     // set while the connection resumes
+	held       [][]byte // frames kept back while holding
+	pending    []int64  // message ids queued and not acked yet
+}
+
+func newClient(subscription Subscription, policy *queuePolicy) *client {
+	return &client{
+		conn:   subscription.conn,
+		roomId: subscription.roomId,
+		sender: subscription.sender,
+		send:   make(chan []byte, policy.size),
+		policy: policy,
+	}
+}
+
+func (c *client) subscription() Subscription {
+	return Subscription{conn: c.conn, roomId: c.roomId, sender: c.sender, client: c}
+}
+
+// enqueue queues a frame, or keeps it back while the client resumes. messageId is the chat
+// message the frame carries, 0 for other frames. It returns false when the client can not
+// keep up and has to be disconnected; its queue is closed then and the hub has to drop it.
+// Frames to a client already closed are ignored.
+func (c *client) enqueue(data []byte, messageId int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return true
+	}
+	if c.holding {
+		if len(c.held) >= constants.MaxHeldFrames {
+			c.closeSlowLocked()
+			return false
+		}
+		c.held = append(c.held, data)
+	} else if !c.push(data) {
+		return false
+	}
+
+	if messageId != 0 {
+		c.track(messageId)
+	}
+	return true
+}
+
+// push queues a frame without waiting. When the queue is full a frame is dropped or the
+// queue is closed, following the slow consumer policy. The caller must hold c.mu.
+func (c *client) push(data []byte) bool {
+	select {
+	case c.send <- data:
+		return true
+	default:
+	}
+
+	switch c.policy.slowConsumer {
+	case constants.SlowConsumerDropNewest:
+		droppedFrames.Add(c.roomId, 1)
+		return true
+	case constants.SlowConsumerDropOldest:
+		select {
+		case <-c.send:
+		default:
+		}
+		droppedFrames.Add(c.roomId, 1)
+		// writePump only takes from the queue, so the freed slot is still there
+		c.send <- data
+		return true
+	}
+
+	c.closeSlowLocked()
+	return false
+}
+
+// close closes the outbound queue, writePump sends the close frame once it is drained
+func (c *client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeLocked()
+}
+
+// closeWith closes the outbound queue, writePump sends the given close frame once it is drained
+func (c *client) closeWith(closeFrame []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeWithLocked(closeFrame)
+}
+
+func (c *client) closeWithLocked(closeFrame []byte) {
+	if !c.closed {
+		c.closeFrame = closeFrame
+		c.closeLocked()
+	}
+}
+
+// closeSlowLocked closes the queue of a client that can not keep up. writePump sends the
+// close code of the queue policy once the frames already queued are written.
+func (c *client) closeSlowLocked() {
+	slowDisconnects.Add(c.roomId, 1)
+	c.closeWithLocked(websocket.FormatCloseMessage(c.policy.closeCode, "slow consumer"))
+}
+
+// isClosed tells whether the outbound queue is closed
+func (c *client) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *client) closeLocked() {
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+// hold starts keeping back the frames queued to the client
+func (c *client) hold() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.holding = true
+		c.held = nil
+	}
+}
+
+// release queues the replayed frames, then the frames kept back except the messages of
+// the room up to lastSeq, which the replay already contained.
+// It returns false when the client can not keep up.
+func (c *client) release(replay [][]byte, lastSeq int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.holding {
+		return true
+	}
+	held := c.held
+	c.holding = false
+	c.held = nil
+
+	for _, data := range replay {
+		if !c.push(data) {
+			return false
+		}
+	}
+	for _, data := range held {
+		if isReplayedMessage(data, c.roomId, lastSeq) {
+			continue
+		}
+		if !c.push(data) {
+			return false
+		}
+	}
+	return true
+}
+
+// track records a chat message queued to the client until the client acks it.
+// The oldest message is forgotten once MaxUnackedMessages are waiting. The caller must hold c.mu.
+func (c *client) track(messageId int64) {
+	if len(c.pending) >= constants.MaxUnackedMessages {
+		c.pending = c.pending[1:]
+	}
+	c.pending = append(c.pending, messageId)
+}
+
+// ack forgets the acknowledged messages
+func (c *client) ack(messageIds []int64) {
+	acked := make(map[int64]bool, len(messageIds))
+	for _, messageId := range messageIds {
+		acked[messageId] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.pending[:0]
+	for _, messageId := range c.pending {
+		if !acked[messageId] {
+			kept = append(kept, messageId)
+		}
+	}
+	c.pending = kept
+}
+
+// takePending returns and forgets the messages waiting for an ack
+func (c *client) takePending() []int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pending := c.pending
+	c.pending = nil
+	return pending
+}
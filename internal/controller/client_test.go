@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"encoding/binary"
+	"expvar"
+	"testing"
+
+	"chating_service/internal/config"
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+func metricValue(metric *expvar.Map, roomId string) int64 {
+	value, ok := metric.Get(roomId).(*expvar.Int)
+	if !ok {
+		return 0
+	}
+	return value.Value()
+}
+
+func queued(c *client) []string {
+	frames := []string{}
+	for len(c.send) > 0 {
+		frames = append(frames, string(<-c.send))
+	}
+	return frames
+}
+
+func TestClientSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		ok      bool
+		queued  []string
+		dropped int64
+	}{
+		{policy: constants.SlowConsumerDropNewest, ok: true, queued: []string{"1", "2"}, dropped: 1},
+		{policy: constants.SlowConsumerDropOldest, ok: true, queued: []string{"2", "3"}, dropped: 1},
+		{policy: constants.SlowConsumerDisconnect, ok: false, queued: []string{"1", "2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			roomId := "room-" + test.policy
+			policy := &queuePolicy{size: 2, slowConsumer: test.policy, closeCode: constants.SlowConsumerCloseCode}
+			c := newClient(Subscription{roomId: roomId, sender: model.Sender{AccountId: 1}}, policy)
+			droppedBefore := metricValue(droppedFrames, roomId)
+			disconnectsBefore := metricValue(slowDisconnects, roomId)
+
+			c.enqueue([]byte("1"), 0)
+			c.enqueue([]byte("2"), 0)
+			if ok := c.enqueue([]byte("3"), 0); ok != test.ok {
+				t.Fatalf("enqueue on a full queue returned %v", ok)
+			}
+
+			frames := queued(c)
+			if len(frames) != len(test.queued) || frames[0] != test.queued[0] || frames[1] != test.queued[1] {
+				t.Fatalf("queued %v, want %v", frames, test.queued)
+			}
+			if dropped := metricValue(droppedFrames, roomId) - droppedBefore; dropped != test.dropped {
+				t.Fatalf("counted %d dropped frames, want %d", dropped, test.dropped)
+			}
+			if !test.ok {
+				if _, open := <-c.send; open {
+					t.Fatal("queue of a disconnected client is still open")
+				}
+				if disconnects := metricValue(slowDisconnects, roomId) - disconnectsBefore; disconnects != 1 {
+					t.Fatalf("counted %d disconnects, want 1", disconnects)
+				}
+				if len(c.closeFrame) < 2 || int(binary.BigEndian.Uint16(c.closeFrame)) != constants.SlowConsumerCloseCode {
+					t.Fatalf("unexpected close frame %q", c.closeFrame)
+				}
+			}
+		})
+	}
+}
+
+func TestNewQueuePolicyDefaults(t *testing.T) {
+	policy := newQueuePolicy(config.WebsocketConfig{})
+	if policy.size != constants.SendBufferSize || policy.slowConsumer != constants.SlowConsumerDisconnect || policy.closeCode != constants.SlowConsumerCloseCode {
+		t.Fatalf("unexpected default policy %+v", policy)
+	}
+
+	policy = newQueuePolicy(config.WebsocketConfig{SendBufferSize: 10, SlowConsumer: "unknown"})
+	if policy.size != constants.MinSendBufferSize || policy.slowConsumer != constants.SlowConsumerDisconnect {
+		t.Fatalf("unexpected policy %+v", policy)
+	}
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -88,3 +89,15 @@ func getLocalCtx(ginCtx *gin.Context) *model.LocalCtx {
 
 	return localCtx.(*model.LocalCtx)
 }
+
+// newHubLocalCtx builds a LocalCtx for work done outside of an http request,
+// such as messages read from a websocket connection.
+func newHubLocalCtx(accountId int64) *model.LocalCtx {
+	DbCtx := db.GetDbConnection(context.Background())
+	RdsCtx := db.GetRedisConnection(context.Background())
+	return &model.LocalCtx{
+		AccountId: accountId,
+		RdbCtx:    &DbCtx,
+		RedisCtx:  &RdsCtx,
+	}
+}
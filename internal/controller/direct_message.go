@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+func GetDirectConversations(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	conversations, err := service.GetDirectConversations(localCtx)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, conversations)
+}
+
+// OpenDirectRoom returns the direct message room with another account.
+// Clients connect to it through the same websocket endpoint as any room.
+func OpenDirectRoom(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	var form model.DirectMessageForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	chatingRoom, err := service.OpenDirectRoom(localCtx, form.AccountId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, chatingRoom)
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+// envelopeError is a protocol error reported back to the client as an error frame.
+// retryAfter tells a limited client when to send the frame again.
+type envelopeError struct {
+	code       int
+	message    string
+	retryAfter time.Duration
+}
+
+func (e *envelopeError) Error() string {
+	return e.message
+}
+
+// parseEnvelope decodes a client frame and validates it against the protocol
+func parseEnvelope(data []byte) (model.Envelope, error) {
+	var envelope model.Envelope
+
+	if len(data) > constants.MaxEnvelopeSize {
+		return envelope, &envelopeError{code: constants.ExceedMaxLength, message: "frame too large"}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&envelope); err != nil {
+		return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed frame"}
+	}
+
+	if envelope.Version != model.EnvelopeVersion {
+		return envelope, &envelopeError{code: constants.InvalidInputData, message: "unsupported protocol version"}
+	}
+
+	switch envelope.Type {
+	case model.EnvelopeTypePing:
+	case model.EnvelopeTypePresence:
+		var payload model.PresencePayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed presence"}
+		}
+		if payload.Status != constants.PresenceOnline && payload.Status != constants.PresenceAway {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "unknown presence status"}
+		}
+	case model.EnvelopeTypeTyping:
+		var payload model.TypingPayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed typing"}
+		}
+	case model.EnvelopeTypeRead:
+		var payload model.ReadPayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil || payload.MessageId <= 0 {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed read"}
+		}
+	case model.EnvelopeTypeResume:
+		var payload model.ResumePayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil || payload.LastSeq < 0 {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed resume"}
+		}
+	case model.EnvelopeTypeAck:
+		var payload model.AckPayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil || len(payload.MessageIds) == 0 {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "malformed ack"}
+		}
+		if len(payload.MessageIds) > constants.MaxAckMessageIds {
+			return envelope, &envelopeError{code: constants.ExceedMaxCount, message: "too many message ids"}
+		}
+	case model.EnvelopeTypeMessage:
+		var payload model.MessagePayload
+		if err := json.Unmarshal(envelope.Payload, &payload); err != nil || (payload.Text == "" && len(payload.AttachmentKeys) == 0) {
+			return envelope, &envelopeError{code: constants.CheckRequiredItems, message: "message text is required"}
+		}
+		if len(payload.AttachmentKeys) > constants.MaxAttachmentsPerMessage {
+			return envelope, &envelopeError{code: constants.ExceedMaxCount, message: "too many attachments"}
+		}
+		if utf8.RuneCountInString(payload.Text) > constants.MaxMessageTextLength {
+			return envelope, &envelopeError{code: constants.ExceedMaxLength, message: "message text too long"}
+		}
+		if payload.ParentId < 0 {
+			return envelope, &envelopeError{code: constants.InvalidInputData, message: "invalid parentId"}
+		}
+	default:
+		return envelope, &envelopeError{code: constants.BadRequest, message: "unknown frame type"}
+	}
+
+	return envelope, nil
+}
+
+// newEnvelope builds a server frame. The payload is marshalled to json.
+func newEnvelope(envelopeType string, roomId string, sender *model.Sender, payload interface{}) (model.Envelope, error) {
+	envelope := model.Envelope{
+		Version: model.EnvelopeVersion,
+		Type:    envelopeType,
+		RoomId:  roomId,
+		Sender:  sender,
+		Ts:      time.Now().UnixMilli(),
+	}
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return envelope, err
+		}
+		envelope.Payload = data
+	}
+	return envelope, nil
+}
+
+// errorFrame encodes the error frame answering the client frame with the given id
+func errorFrame(roomId string, id string, err error) []byte {
+	payload := model.ErrorPayload{Code: constants.ServerInternalError, Message: "internal error"}
+
+	var envErr *envelopeError
+	if errors.As(err, &envErr) {
+		payload = model.ErrorPayload{Code: envErr.code, Message: envErr.message, RetryAfterMs: envErr.retryAfter.Milliseconds()}
+	} else if code := roomErrorCode(err); code != constants.ServerInternalError {
+		payload = model.ErrorPayload{Code: code, Message: err.Error()}
+	}
+
+	envelope, _ := newEnvelope(model.EnvelopeTypeError, roomId, nil, payload)
+	envelope.Id = id
+	data, _ := json.Marshal(envelope)
+	return data
+}
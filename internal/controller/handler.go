@@ -1,13 +1,29 @@
 package controller
 
 import (
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/config"
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/notifier"
+	"chating_service/internal/pubsub"
+	"chating_service/internal/repo"
+	"chating_service/internal/service"
 )
 
 var upgrader = websocket.Upgrader{
@@ -18,119 +34,618 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// Hub keeps the websocket connections of this instance.
+// Room broadcasts go through the broker so that every instance with members of the room
+// delivers them; the hub only subscribes to the rooms it has local members of.
+// Likewise it subscribes to the user topic of every account connected to it.
+// Rooms and accounts are spread over shards, each fanning out its broadcasts on its own
+// goroutine. Registering and broadcasting never wait for another shard, and a broadcast
+// takes no lock but the one of each client it is queued to.
 type Hub struct {
-	rooms      map[string]map[*websocket.Conn]chan []byte
-	broadcast  chan Message
-	register   chan Subscription
-	unregister chan Subscription
-	mu         sync.Mutex
+	shards   [constants.HubShardCount]*hubShard
+	broker   pubsub.Broker
+	queue    queuePolicy
+	timeouts connTimeouts
+	// allowedOrigins may open a websocket authenticated by the token cookie
+	allowedOrigins []string
+
+	notifier notifier.Notifier
+	pushes   chan pushJob
+
+	// serve reads the frames of a registered connection until it closes.
+	// It is readPump, tests replace it to run the hub without mysql and redis.
+	serve func(subscription Subscription)
+	// keepUnacked stores the messages a closed connection never acked, it runs in its own goroutine
+	keepUnacked func(subscription Subscription, messageIds []int64)
+
+	lifecycle  sync.Mutex     // orders registrations and the start of a shutdown
+	closing    atomic.Bool    // set once the hub shuts down
+	serving    sync.WaitGroup // pumps of the registered connections
+	background sync.WaitGroup // push worker and unacked messages being kept
 }
+
+// Message is a broadcast to fan out to the local clients of a room, or of an account when
+// accountId is set
 type Message struct {
-	roomId string
-	data   []byte
+	roomId         string
+	data           []byte
+	excludeAccount int64 // if set, the connections of this account are skipped
+	messageId      int64 // if set, the frame carries this chat message and waits for an ack
+
+	accountId  int64  // if set, the message goes to the connections of this account
+	skipRoomId string // if set, the connections of the account to this room are skipped
 }
 
 type Subscription struct {
 	conn   *websocket.Conn
 	roomId string
+	sender model.Sender
+	client *client // set once the connection is registered
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan Message),
-		register:   make(chan Subscription),
-		unregister: make(chan Subscription),
-		rooms:      make(map[string]map[*websocket.Conn]chan []byte),
+func NewHub(broker pubsub.Broker, pushNotifier notifier.Notifier, wsConfig config.WebsocketConfig) *Hub {
+	hub := &Hub{
+		broker:   broker,
+		queue:    newQueuePolicy(wsConfig),
+		timeouts: newConnTimeouts(wsConfig),
+		notifier: pushNotifier,
+		pushes:   make(chan pushJob, constants.PushQueueSize),
+
+		allowedOrigins: wsConfig.AllowedOrigins,
+	}
+	for i := range hub.shards {
+		hub.shards[i] = newHubShard(broker)
 	}
+	hub.serve = hub.readPump
+	hub.keepUnacked = keepUnacked
+	return hub
 }
 
+// Run fans out the broadcasts received from the broker until it is closed
 func (h *Hub) Run() {
-	for {
-		select {
-		case subscription := <-h.register:
-			h.mu.Lock()
-			if _, ok := h.rooms[subscription.roomId]; !ok {
-				h.rooms[subscription.roomId] = make(map[*websocket.Conn]chan []byte)
+	err := h.broker.Subscribe(context.Background(), pubsub.ControlTopic)
+	if err != nil {
+		log.Err(err).Msg("Failed to subscribe hub control topic")
+	}
+	h.lifecycle.Lock()
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		h.runPushWorker()
+	}()
+	h.lifecycle.Unlock()
+	for _, shard := range h.shards {
+		go shard.run(h)
+	}
+	defer func() {
+		for _, shard := range h.shards {
+			close(shard.inbox)
+		}
+	}()
+
+	for published := range h.broker.Messages() {
+		if published.Topic == pubsub.ControlTopic {
+			h.handleControl(published.Data)
+			continue
+		}
+		if accountId, ok := pubsub.AccountIdFromTopic(published.Topic); ok {
+			skipRoomId, data, err := decodeUserBroadcast(published.Data)
+			if err != nil {
+				log.Err(err).Msgf("Invalid user broadcast. account : %d", accountId)
+				continue
 			}
-			h.rooms[subscription.roomId][subscription.conn] = make(chan []byte)
-			go h.writePump(subscription.conn, subscription.roomId)
-			go h.readPump(subscription.conn, subscription.roomId)
-			h.mu.Unlock()
-		case subscription := <-h.unregister:
-			h.mu.Lock()
-			if _, ok := h.rooms[subscription.roomId]; ok {
-				if _, ok := h.rooms[subscription.roomId][subscription.conn]; ok {
-					close(h.rooms[subscription.roomId][subscription.conn])
-					delete(h.rooms[subscription.roomId], subscription.conn)
-					if len(h.rooms[subscription.roomId]) == 0 {
-						delete(h.rooms, subscription.roomId)
-					}
-				}
+			h.userShard(accountId).inbox <- Message{accountId: accountId, skipRoomId: skipRoomId, data: data}
+			continue
+		}
+		roomId, ok := pubsub.RoomIdFromTopic(published.Topic)
+		if !ok {
+			continue
+		}
+		message, err := decodeRoomBroadcast(roomId, published.Data)
+		if err != nil {
+			log.Err(err).Msgf("Invalid room broadcast. room : %s", roomId)
+			continue
+		}
+		h.roomShard(roomId).inbox <- message
+	}
+	log.Error().Msg("Hub broker closed")
+}
 
+// register adds a connection to the hub and starts serving it
+func (h *Hub) register(subscription Subscription) {
+	h.lifecycle.Lock()
+	defer h.lifecycle.Unlock()
+
+	if h.closing.Load() {
+		// the hub started shutting down during the upgrade
+		subscription.conn.WriteControl(websocket.CloseMessage, goingAwayFrame(), time.Now().Add(constants.CloseFrameTimeout))
+		subscription.conn.Close()
+		return
+	}
+
+	c := newClient(subscription, &h.queue)
+	c.deadline = h.timeouts.watch(c.conn)
+	h.add(c)
+
+	h.serving.Add(2)
+	go func() {
+		defer h.serving.Done()
+		h.writePump(c)
+	}()
+	go func() {
+		defer h.serving.Done()
+		h.serve(c.subscription())
+	}()
+}
+
+// add makes a client a member of its room and account groups.
+// Once in its room the client can be unregistered before it joins its account group; unregister
+// closes its queue before leaving the account group, so one of the two always takes it out.
+func (h *Hub) add(c *client) {
+	h.roomShard(c.roomId).joinRoom(c)
+	h.userShard(c.sender.AccountId).joinUser(c)
+	if c.isClosed() {
+		h.userShard(c.sender.AccountId).leaveUser(c)
+	}
+}
+
+// unregister removes a client from the hub and closes its outbound queue.
+// The messages it did not ack are kept for the next connection of the account to the room.
+func (h *Hub) unregister(c *client) {
+	if !h.roomShard(c.roomId).leaveRoom(c) {
+		return
+	}
+	c.close()
+	h.userShard(c.sender.AccountId).leaveUser(c)
+
+	if messageIds := c.takePending(); len(messageIds) > 0 {
+		h.background.Add(1)
+		go func() {
+			defer h.background.Done()
+			h.keepUnacked(c.subscription(), messageIds)
+		}()
+	}
+}
+
+// roomMembers returns the local clients of the room
+func (h *Hub) roomMembers(roomId string) []*client {
+	return h.roomShard(roomId).roomMembers(roomId)
+}
+
+// userMembers returns the local clients of the account
+func (h *Hub) userMembers(accountId int64) []*client {
+	return h.userShard(accountId).userMembers(accountId)
+}
+
+// fanOut delivers a broadcast to the local clients of its room or account
+func (h *Hub) fanOut(message Message) {
+	if message.accountId != 0 {
+		for _, c := range h.userMembers(message.accountId) {
+			if message.skipRoomId != "" && c.roomId == message.skipRoomId {
+				continue
 			}
-			h.mu.Unlock()
-		case message := <-h.broadcast:
-			h.mu.Lock()
-			for conn, send := range h.rooms[message.roomId] {
-				select {
-				case send <- message.data:
-				default:
-					close(send)
-					delete(h.rooms[message.roomId], conn)
-					conn.Close()
-				}
+			h.deliver(c, message.data, 0)
+		}
+		return
+	}
+
+	for _, c := range h.roomMembers(message.roomId) {
+		if message.excludeAccount != 0 && c.sender.AccountId == message.excludeAccount {
+			continue
+		}
+		h.deliver(c, message.data, message.messageId)
+	}
+}
+
+// deliver queues a frame to a client. A client that can not keep up is dropped.
+func (h *Hub) deliver(c *client, data []byte, messageId int64) bool {
+	if c.enqueue(data, messageId) {
+		return true
+	}
+	h.drop(c)
+	return false
+}
+
+// drop unregisters a client that can not keep up. Its queue is already closed with the close
+// frame of the queue policy, which writePump sends; the fan-out never writes to the socket.
+func (h *Hub) drop(c *client) {
+	log.Warn().Msgf("Dropped slow connection. room : %s, account : %d", c.roomId, c.sender.AccountId)
+	h.unregister(c)
+}
+
+// publishToAccounts sends a message to every connection of the accounts on every instance.
+// Connections to skipRoomId are left out when it is not empty, e.g. because they already
+// received the room broadcast the message is about.
+func (h *Hub) publishToAccounts(accountIds []int64, skipRoomId string, data []byte) error {
+	framed := encodeUserBroadcast(skipRoomId, data)
+	for _, accountId := range accountIds {
+		err := h.broker.Publish(context.Background(), pubsub.UserTopic(accountId), framed)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeUserBroadcast frames a user broadcast for the broker:
+// 2 byte big endian length of the skipped room id, the room id and the data
+func encodeUserBroadcast(skipRoomId string, data []byte) []byte {
+	framed := make([]byte, 2+len(skipRoomId)+len(data))
+	binary.BigEndian.PutUint16(framed, uint16(len(skipRoomId)))
+	copy(framed[2:], skipRoomId)
+	copy(framed[2+len(skipRoomId):], data)
+	return framed
+}
+
+func decodeUserBroadcast(framed []byte) (string, []byte, error) {
+	if len(framed) < 2 {
+		return "", nil, errors.New("user broadcast too short")
+	}
+	length := int(binary.BigEndian.Uint16(framed))
+	if len(framed) < 2+length {
+		return "", nil, errors.New("user broadcast too short")
+	}
+	return string(framed[2 : 2+length]), framed[2+length:], nil
+}
+
+// hubControl is an instruction shared by every hub through pubsub.ControlTopic
+type hubControl struct {
+	Action    string `json:"action"`
+	RoomId    string `json:"roomId"`
+	AccountId int64  `json:"accountId"`
+}
+
+const (
+	hubControlDisconnect = "disconnect"
+	hubControlCloseRoom  = "closeRoom"
+)
+
+// disconnectMember closes the connections of the account to the room on every instance,
+// e.g. after the account left or was kicked from the room
+func (h *Hub) disconnectMember(roomId string, accountId int64) error {
+	data, err := json.Marshal(hubControl{Action: hubControlDisconnect, RoomId: roomId, AccountId: accountId})
+	if err != nil {
+		return err
+	}
+	return h.broker.Publish(context.Background(), pubsub.ControlTopic, data)
+}
+
+// closeRoom closes every connection to the room on every instance,
+// e.g. after the room was archived or deleted
+func (h *Hub) closeRoom(roomId string) error {
+	data, err := json.Marshal(hubControl{Action: hubControlCloseRoom, RoomId: roomId})
+	if err != nil {
+		return err
+	}
+	return h.broker.Publish(context.Background(), pubsub.ControlTopic, data)
+}
+
+func (h *Hub) handleControl(data []byte) {
+	var control hubControl
+	if err := json.Unmarshal(data, &control); err != nil {
+		log.Err(err).Msg("Invalid hub control message")
+		return
+	}
+
+	switch control.Action {
+	case hubControlDisconnect:
+		for _, c := range h.roomMembers(control.RoomId) {
+			if c.sender.AccountId == control.AccountId {
+				// writePump sends the close frame once the queue is closed
+				h.unregister(c)
 			}
-			h.mu.Unlock()
 		}
+	case hubControlCloseRoom:
+		for _, c := range h.roomMembers(control.RoomId) {
+			h.unregister(c)
+		}
+	}
+}
+
+// publish sends a message to every member of the room on every instance
+func (h *Hub) publish(roomId string, data []byte) error {
+	return h.publishExcept(roomId, 0, data)
+}
+
+// publishExcept sends a message to every member of the room on every instance but the given account
+func (h *Hub) publishExcept(roomId string, excludeAccount int64, data []byte) error {
+	return h.broker.Publish(context.Background(), pubsub.RoomTopic(roomId), encodeRoomBroadcast(excludeAccount, 0, data))
+}
+
+// publishMessage sends the frame of a chat message to every member of the room on every instance.
+// Every connection keeps the message until its client acks it.
+func (h *Hub) publishMessage(roomId string, messageId int64, data []byte) error {
+	return h.broker.Publish(context.Background(), pubsub.RoomTopic(roomId), encodeRoomBroadcast(0, messageId, data))
+}
+
+// encodeRoomBroadcast frames a room broadcast for the broker: the excluded account id and
+// the id of the chat message carried, 0 for other frames, as 8 big endian bytes each,
+// followed by the frame sent to clients
+func encodeRoomBroadcast(excludeAccount int64, messageId int64, data []byte) []byte {
+	framed := make([]byte, 16+len(data))
+	binary.BigEndian.PutUint64(framed, uint64(excludeAccount))
+	binary.BigEndian.PutUint64(framed[8:], uint64(messageId))
+	copy(framed[16:], data)
+	return framed
+}
+
+func decodeRoomBroadcast(roomId string, framed []byte) (Message, error) {
+	if len(framed) < 16 {
+		return Message{}, errors.New("room broadcast too short")
 	}
+	return Message{
+		roomId:         roomId,
+		data:           framed[16:],
+		excludeAccount: int64(binary.BigEndian.Uint64(framed)),
+		messageId:      int64(binary.BigEndian.Uint64(framed[8:])),
+	}, nil
 }
 
-func (h *Hub) writePump(conn *websocket.Conn, roomId string) {
+// writePump writes the frames queued to a client until its queue is closed, and pings the
+// client between them. A write taking longer than the write wait fails.
+func (h *Hub) writePump(c *client) {
+	ticker := time.NewTicker(h.timeouts.pingInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case message, ok := <-h.rooms[roomId][conn]:
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(h.timeouts.writeWait))
 			if !ok {
-				// Hub가 채널을 닫음
-				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				// Hub가 채널을 닫음, closeFrame은 채널을 닫기 전에 설정된다
+				c.conn.WriteMessage(websocket.CloseMessage, c.closeFrame)
 				return
 			}
 
-			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
 				log.Err(err).Msg("Failed to write message")
+				// readPump fails on the closed connection and unregisters it,
+				// which keeps its unacked messages for the next connection
+				c.conn.Close()
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(h.timeouts.writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Err(err).Msg("Failed to write ping")
+				c.conn.Close()
 				return
 			}
 		}
 	}
 }
 
-func (h *Hub) readPump(conn *websocket.Conn, roomId string) {
+func (h *Hub) readPump(subscription Subscription) {
+	conn := subscription.conn
+	roomId := subscription.roomId
+	localCtx := newHubLocalCtx(subscription.sender.AccountId)
+
+	presence := &connPresence{hub: h, localCtx: localCtx, subscription: subscription}
+	presence.connect()
+	// a client that only answers pings stays online until the idle timeout closes it
+	subscription.client.deadline.onPong = func() {
+		presence.touch(presence.status)
+	}
+	limiter := newConnLimiter(h, localCtx, subscription)
+
+	err := h.redeliver(localCtx, subscription)
+	if err != nil {
+		log.Err(err).Msgf("Failed to redeliver unacked messages. room : %s", roomId)
+	}
+
 	defer func() {
-		h.unregister <- Subscription{conn: conn, roomId: roomId}
+		h.unregister(subscription.client)
 		conn.Close()
+		presence.disconnect()
 	}()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+			if subscription.client.deadline.idle() {
+				log.Info().Msgf("Closing idle connection. room : %s, account : %d", roomId, subscription.sender.AccountId)
+				closeFrame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle timeout")
+				conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(constants.CloseFrameTimeout))
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Err(err).Msg("Unexpected close error")
 			} else {
 				log.Err(err).Msg("Failed to read message")
 			}
 			break
 		}
-		var msg map[string]interface{}
-		if err := json.Unmarshal(message, &msg); err == nil {
-			if msg["type"] == "ping" {
-				log.Info().Msg("Received ping")
-				continue
+		subscription.client.deadline.active()
+
+		envelope, err := parseEnvelope(data)
+		if !limiter.allow(envelope, err) {
+			continue
+		}
+		if err != nil {
+			log.Warn().Msgf("Rejected frame from account %d : %v", subscription.sender.AccountId, err)
+			h.sendTo(subscription, errorFrame(roomId, envelope.Id, err))
+			continue
+		}
+
+		switch envelope.Type {
+		case model.EnvelopeTypePing:
+			presence.touch(presence.status)
+			pong, _ := newEnvelope(model.EnvelopeTypePong, roomId, nil, nil)
+			h.sendEnvelopeTo(subscription, pong)
+		case model.EnvelopeTypePresence:
+			var payload model.PresencePayload
+			json.Unmarshal(envelope.Payload, &payload)
+			presence.touch(payload.Status)
+		case model.EnvelopeTypeTyping:
+			h.handleTyping(localCtx, subscription, envelope)
+		case model.EnvelopeTypeRead:
+			err = h.handleRead(localCtx, subscription, envelope)
+			if err != nil {
+				log.Err(err).Msgf("Failed to handle read. room : %s", roomId)
+				h.sendTo(subscription, errorFrame(roomId, envelope.Id, err))
+			}
+		case model.EnvelopeTypeAck:
+			var payload model.AckPayload
+			json.Unmarshal(envelope.Payload, &payload)
+			subscription.client.ack(payload.MessageIds)
+		case model.EnvelopeTypeResume:
+			err = h.handleResume(localCtx, subscription, envelope)
+			if err != nil {
+				log.Err(err).Msgf("Failed to handle resume. room : %s", roomId)
+				h.sendTo(subscription, errorFrame(roomId, envelope.Id, err))
+			}
+		case model.EnvelopeTypeMessage:
+			presence.touch(constants.PresenceOnline)
+			err = h.handleChatMessage(localCtx, subscription, envelope)
+			if err != nil {
+				log.Err(err).Msgf("Failed to handle message. room : %s", roomId)
+				h.sendTo(subscription, errorFrame(roomId, envelope.Id, err))
 			}
 		}
-		h.broadcast <- Message{roomId: roomId, data: message}
 	}
 }
 
+// handleChatMessage stores a chat message and broadcasts it to the room.
+// The sender and timestamp always come from the server, whatever the client wrote.
+func (h *Hub) handleChatMessage(localCtx *model.LocalCtx, subscription Subscription, envelope model.Envelope) error {
+	var payload model.MessagePayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		return err
+	}
+
+	// readPump runs per connection, so the insert never holds up Hub.Run
+	message, err := service.SaveMessage(localCtx, subscription.roomId, model.NewMessageForm{
+		Body:           payload.Text,
+		ParentId:       payload.ParentId,
+		AttachmentKeys: payload.AttachmentKeys,
+	})
+	if err != nil {
+		return err
+	}
+	h.sendDelivered(subscription, envelope.Id, message)
+
+	out, err := messageEnvelope(subscription.sender, message)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	err = h.publishMessage(subscription.roomId, message.Id, data)
+	if err != nil {
+		return err
+	}
+
+	var mentioned []int64
+	if len(message.Mentions) > 0 || message.MentionAll != "" {
+		mentioned, err = service.GetMentionRecipients(localCtx, message)
+		if err != nil {
+			return err
+		}
+		err = h.notifyMentions(subscription, out.Id, message, mentioned)
+		if err != nil {
+			return err
+		}
+	}
+	h.queuePush(pushJob{sender: subscription.sender, message: message, mentioned: mentioned})
+
+	if payload.ParentId == 0 {
+		return nil
+	}
+	return h.notifyThreadParticipants(localCtx, subscription, out.Id, message)
+}
+
+// notifyMentions sends a mention event to the mentioned accounts. Their connections to the
+// room already got the message, so only their connections to other rooms receive it.
+func (h *Hub) notifyMentions(subscription Subscription, id string, message model.Message, accountIds []int64) error {
+	payload := model.MentionPayload{Text: message.Body, MentionAll: message.MentionAll}
+	out, err := newEnvelope(model.EnvelopeTypeMention, subscription.roomId, &subscription.sender, payload)
+	if err != nil {
+		return err
+	}
+	out.Id = id
+	out.Ts = message.CreatedAt.UnixMilli()
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return h.publishToAccounts(accountIds, subscription.roomId, data)
+}
+
+// notifyThreadParticipants tells everyone who took part in the thread of a reply, except
+// its sender, about the reply on their own connections in any room
+func (h *Hub) notifyThreadParticipants(localCtx *model.LocalCtx, subscription Subscription, id string, message model.Message) error {
+	accountIds, err := service.GetThreadParticipants(localCtx, *message.ParentId)
+	if err != nil {
+		return err
+	}
+
+	payload := model.ThreadReplyPayload{ParentId: *message.ParentId, Text: message.Body}
+	out, err := newEnvelope(model.EnvelopeTypeThreadReply, subscription.roomId, &subscription.sender, payload)
+	if err != nil {
+		return err
+	}
+	out.Id = id
+	out.Ts = message.CreatedAt.UnixMilli()
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+
+	recipients := make([]int64, 0, len(accountIds))
+	for _, accountId := range accountIds {
+		if accountId != localCtx.AccountId {
+			recipients = append(recipients, accountId)
+		}
+	}
+	return h.publishToAccounts(recipients, "", data)
+}
+
+// messageEnvelope builds the frame broadcasting a stored message
+func messageEnvelope(sender model.Sender, message model.Message) (model.Envelope, error) {
+	payload := model.MessagePayload{
+		Text:        message.Body,
+		Attachments: message.Attachments,
+		Mentions:    message.Mentions,
+		MentionAll:  message.MentionAll,
+	}
+	if message.ParentId != nil {
+		payload.ParentId = *message.ParentId
+	}
+
+	out, err := newEnvelope(model.EnvelopeTypeMessage, message.RoomId, &sender, payload)
+	if err != nil {
+		return out, err
+	}
+	out.Id = strconv.FormatInt(message.Id, 10)
+	out.Seq = message.Seq
+	out.Ts = message.CreatedAt.UnixMilli()
+	return out, nil
+}
+
+// sendTo delivers data only to the connection of the subscription
+func (h *Hub) sendTo(subscription Subscription, data []byte) {
+	h.deliver(subscription.client, data, 0)
+}
+
+func (h *Hub) sendEnvelopeTo(subscription Subscription, envelope model.Envelope) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		log.Err(err).Msg("Failed to marshal envelope")
+		return
+	}
+	h.sendTo(subscription, data)
+}
+
 func WebsocketHandler(hub *Hub, ginCtx *gin.Context) {
+	if hub.closing.Load() {
+		ginCtx.Header("Retry-After", strconv.Itoa(int(constants.ReconnectJitter.Seconds())))
+		ginCtx.JSON(http.StatusServiceUnavailable, gin.H{"error": "server is shutting down"})
+		return
+	}
+
 	roomId := ginCtx.Param("roomId")
 	if roomId == "" {
 		log.Warn().Msg("roomId is required")
@@ -138,27 +653,101 @@ func WebsocketHandler(hub *Hub, ginCtx *gin.Context) {
 		return
 	}
 
-	// tokenString := ginCtx.Query("token")
-	// if tokenString == "" {
-	// 	ginCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
-	// 	return
-	// }
+	tokenString, protocol, fromCookie := websocketToken(ginCtx)
+	if tokenString == "" {
+		ginCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token is required"})
+		return
+	}
+	// 쿠키는 다른 사이트의 페이지에서 연 웹소켓에도 실리므로 허용된 오리진만 쿠키로 인증한다
+	if fromCookie && !hub.allowsOrigin(ginCtx.Request) {
+		log.Warn().Msgf("Rejected websocket cookie from origin %q", ginCtx.GetHeader("Origin"))
+		ginCtx.JSON(http.StatusForbidden, gin.H{"error": "Origin not allowed"})
+		return
+	}
+
+	claims, err := parseAccessToken(tokenString)
+	if err != nil {
+		log.Warn().Msgf("Invalid websocket token : %v", err)
+		ginCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+		return
+	}
+
+	// 브라우저는 subprotocol로 토큰을 보낸 경우 서버가 같은 protocol을 응답해야 연결을 유지한다
+	var responseHeader http.Header
+	if protocol != "" {
+		responseHeader = http.Header{"Sec-WebSocket-Protocol": []string{protocol}}
+	}
 
-	// // JWT 토큰 검증
-	// token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-	// 	// 검증 키를 반환
-	// 	return []byte("your_secret_key"), nil
-	// })
+	localCtx := getLocalCtx(ginCtx)
+	localCtx.AccountId = claims.ID
+	err = service.CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		log.Warn().Msgf("Websocket access denied. room : %s, account : %d, %v", roomId, claims.ID, err)
+		ginCtx.JSON(roomErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
 
-	// if err != nil || !token.Valid {
-	// 	ginCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
-	// 	return
-	// }
+	account, err := repo.GetUserAccountByAccountId(localCtx.RdbCtx, claims.ID)
+	if err != nil {
+		log.Err(err).Msgf("Failed to get account of websocket token : %d", claims.ID)
+		ginCtx.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization token"})
+		return
+	}
 
-	conn, err := upgrader.Upgrade(ginCtx.Writer, ginCtx.Request, nil)
+	conn, err := upgrader.Upgrade(ginCtx.Writer, ginCtx.Request, responseHeader)
 	if err != nil {
 		log.Err(err).Msgf("Failed to upgrade connection : %v", err)
 		return
 	}
-	hub.register <- Subscription{conn: conn, roomId: roomId}
+	sender := model.Sender{AccountId: account.Id, UserId: account.UserId}
+	hub.register(Subscription{conn: conn, roomId: roomId, sender: sender})
+}
+
+// websocketToken finds the access token of a websocket upgrade request.
+// Browsers cannot set the Authorization header on a websocket, so the token is read from
+// the "token" query, the "access_token, <token>" subprotocol pair or the token cookie.
+// The returned protocol is the subprotocol to echo back, if the token came from there,
+// and fromCookie tells whether the browser sent the token on its own.
+func websocketToken(ginCtx *gin.Context) (token string, protocol string, fromCookie bool) {
+	if token := ginCtx.Query("token"); token != "" {
+		return token, "", false
+	}
+
+	protocols := websocket.Subprotocols(ginCtx.Request)
+	for i, protocol := range protocols {
+		if protocol == constants.WebsocketTokenProtocol && i+1 < len(protocols) {
+			return protocols[i+1], constants.WebsocketTokenProtocol, false
+		}
+	}
+
+	for _, name := range []string{"jwt", "token"} {
+		if token, err := ginCtx.Cookie(name); err == nil && token != "" {
+			return token, "", true
+		}
+	}
+
+	return "", "", false
+}
+
+// allowsOrigin tells whether the page that opened a websocket may use the token cookie:
+// a page of this server or of one of the allowed origins. Requests without an Origin
+// header do not come from a browser page.
+func (h *Hub) allowsOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	originUrl, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(originUrl.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range h.allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
 }
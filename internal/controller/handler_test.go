@@ -0,0 +1,367 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"chating_service/internal/config"
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/notifier"
+	"chating_service/internal/pubsub"
+)
+
+// newTestHub returns a running hub whose connections are only read until they close
+func newTestHub(broker pubsub.Broker) *Hub {
+	hub := NewHub(broker, notifier.NewFakeNotifier(), config.WebsocketConfig{})
+	hub.serve = func(subscription Subscription) {
+		defer func() {
+			hub.unregister(subscription.client)
+			subscription.conn.Close()
+		}()
+		for {
+			if _, _, err := subscription.conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+	go hub.Run()
+	return hub
+}
+
+// newTestClient connects a websocket client registered to the hub, skipping authentication
+func newTestClient(t *testing.T, hub *Hub, roomId string, accountId int64) *websocket.Conn {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		hub.register(Subscription{conn: conn, roomId: roomId, sender: model.Sender{AccountId: accountId}})
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitForMembers(t *testing.T, hub *Hub, roomId string, count int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if len(hub.roomMembers(roomId)) == count {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("room %s did not reach %d members", roomId, count)
+}
+
+func TestHubFanOutAcrossInstances(t *testing.T) {
+	bus := pubsub.NewMemoryBus()
+	first := newTestHub(bus.NewBroker())
+	second := newTestHub(bus.NewBroker())
+
+	firstConn := newTestClient(t, first, "room-1", 1)
+	secondConn := newTestClient(t, second, "room-1", 2)
+	otherConn := newTestClient(t, second, "room-2", 3)
+	waitForMembers(t, first, "room-1", 1)
+	waitForMembers(t, second, "room-1", 1)
+	waitForMembers(t, second, "room-2", 1)
+
+	if err := first.publish("room-1", []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	for _, conn := range []*websocket.Conn{firstConn, secondConn} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(data) != "hello" {
+			t.Fatalf("unexpected message %s", data)
+		}
+	}
+
+	otherConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := otherConn.ReadMessage(); err == nil {
+		t.Fatalf("member of another room received %s", data)
+	}
+}
+
+func TestHubPublishToAccountsSkipsRoom(t *testing.T) {
+	bus := pubsub.NewMemoryBus()
+	first := newTestHub(bus.NewBroker())
+	second := newTestHub(bus.NewBroker())
+
+	inRoomConn := newTestClient(t, first, "room-1", 1)
+	elsewhereConn := newTestClient(t, second, "room-2", 1)
+	waitForMembers(t, first, "room-1", 1)
+	waitForMembers(t, second, "room-2", 1)
+
+	if err := first.publishToAccounts([]int64{1}, "room-1", []byte("mention")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	elsewhereConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := elsewhereConn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(data) != "mention" {
+		t.Fatalf("unexpected message %s", data)
+	}
+
+	inRoomConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, data, err := inRoomConn.ReadMessage(); err == nil {
+		t.Fatalf("connection to the skipped room received %s", data)
+	}
+}
+
+func TestHubReleaseSkipsReplayedMessages(t *testing.T) {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	conn := newTestClient(t, hub, "room-1", 1)
+	waitForMembers(t, hub, "room-1", 1)
+
+	c := hub.roomMembers("room-1")[0]
+
+	frame := func(seq int64) []byte {
+		return []byte(fmt.Sprintf(`{"type":"message","roomId":"room-1","seq":%d}`, seq))
+	}
+
+	c.hold()
+	for _, seq := range []int64{2, 3} {
+		if err := hub.publish("room-1", frame(seq)); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mu.Lock()
+		held := len(c.held)
+		c.mu.Unlock()
+		if held == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("held %d frames, want 2", held)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	resumed := []byte(`{"type":"resume","roomId":"room-1"}`)
+	hub.release(c, [][]byte{frame(1), frame(2), resumed}, 2)
+
+	for _, want := range [][]byte{frame(1), frame(2), resumed, frame(3)} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(data) != string(want) {
+			t.Fatalf("got %s, want %s", data, want)
+		}
+	}
+}
+
+func TestHubKeepsUnackedMessagesOnClose(t *testing.T) {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	kept := make(chan []int64, 1)
+	hub.keepUnacked = func(subscription Subscription, messageIds []int64) {
+		kept <- messageIds
+	}
+
+	conn := newTestClient(t, hub, "room-1", 1)
+	waitForMembers(t, hub, "room-1", 1)
+
+	for _, messageId := range []int64{7, 8, 9} {
+		if err := hub.publishMessage("room-1", messageId, []byte("message")); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, _, err := conn.ReadMessage(); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+
+	c := hub.roomMembers("room-1")[0]
+	c.ack([]int64{8})
+
+	conn.Close()
+	select {
+	case messageIds := <-kept:
+		if fmt.Sprint(messageIds) != "[7 9]" {
+			t.Fatalf("kept %v, want [7 9]", messageIds)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("unacked messages were not kept")
+	}
+}
+
+func newHeartbeatTestHub(idleTimeout time.Duration) *Hub {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	hub.timeouts = connTimeouts{
+		pingInterval:   20 * time.Millisecond,
+		pongWait:       250 * time.Millisecond,
+		writeWait:      time.Second,
+		idleTimeout:    idleTimeout,
+		maxMessageSize: 1024,
+	}
+	return hub
+}
+
+// readUntilClosed reads a client connection in the background, which answers the pings
+func readUntilClosed(conn *websocket.Conn) {
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+func TestHubClosesConnectionWithoutPongs(t *testing.T) {
+	hub := newHeartbeatTestHub(0)
+	newTestClient(t, hub, "room-1", 1)
+	waitForMembers(t, hub, "room-1", 1)
+
+	// the client never reads, so it never answers the pings
+	waitForMembers(t, hub, "room-1", 0)
+}
+
+func TestHubKeepsConnectionAnsweringPings(t *testing.T) {
+	hub := newHeartbeatTestHub(0)
+	conn := newTestClient(t, hub, "room-1", 1)
+	readUntilClosed(conn)
+	waitForMembers(t, hub, "room-1", 1)
+
+	time.Sleep(600 * time.Millisecond)
+	if members := len(hub.roomMembers("room-1")); members != 1 {
+		t.Fatalf("connection answering pings was closed")
+	}
+}
+
+func TestHubClosesIdleConnection(t *testing.T) {
+	hub := newHeartbeatTestHub(400 * time.Millisecond)
+	conn := newTestClient(t, hub, "room-1", 1)
+	readUntilClosed(conn)
+	waitForMembers(t, hub, "room-1", 1)
+
+	// pongs alone do not keep the connection open past the idle timeout
+	waitForMembers(t, hub, "room-1", 0)
+}
+
+func TestHubClosesConnectionOverReadLimit(t *testing.T) {
+	hub := newHeartbeatTestHub(0)
+	conn := newTestClient(t, hub, "room-1", 1)
+	readUntilClosed(conn)
+	waitForMembers(t, hub, "room-1", 1)
+
+	if err := conn.WriteMessage(websocket.TextMessage, make([]byte, 2048)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	waitForMembers(t, hub, "room-1", 0)
+}
+
+func TestHubShutdownFlushesAndClosesGoingAway(t *testing.T) {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	conn := newTestClient(t, hub, "room-1", 1)
+	waitForMembers(t, hub, "room-1", 1)
+	hub.deliver(hub.roomMembers("room-1")[0], []byte("last"), 0)
+
+	// the client answers the close frame while reading
+	closed := make(chan error, 1)
+	go func() {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil || string(data) != "last" {
+			closed <- fmt.Errorf("queued frame not flushed: %s, %v", data, err)
+			return
+		}
+		_, _, err = conn.ReadMessage()
+		closed <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	err := <-closed
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Fatalf("expected a going away close frame, got %v", err)
+	}
+	var hint reconnectHint
+	if err := json.Unmarshal([]byte(closeErr.Text), &hint); err != nil || !hint.Reconnect {
+		t.Fatalf("unexpected reconnect hint %q", closeErr.Text)
+	}
+	if members := len(hub.roomMembers("room-1")); members != 0 {
+		t.Fatalf("%d members left after shutdown", members)
+	}
+}
+
+func TestHubAllowsOrigin(t *testing.T) {
+	hub := NewHub(pubsub.NewMemoryBus().NewBroker(), notifier.NewFakeNotifier(),
+		config.WebsocketConfig{AllowedOrigins: []string{"https://chat.example.com/"}})
+
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{origin: "", ok: true},
+		{origin: "http://api.example.com", ok: true},
+		{origin: "https://chat.example.com", ok: true},
+		{origin: "https://evil.example.com", ok: false},
+		{origin: "http://chat.example.com", ok: false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://api.example.com/chating/room-1", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if ok := hub.allowsOrigin(r); ok != test.ok {
+			t.Fatalf("origin %q allowed %v, want %v", test.origin, ok, test.ok)
+		}
+	}
+}
+
+func TestConnTimeoutsPingInterval(t *testing.T) {
+	timeouts := newConnTimeouts(config.WebsocketConfig{})
+	if timeouts.pingInterval != constants.PingInterval || timeouts.pongWait != constants.PongWait {
+		t.Fatalf("unexpected default timeouts %+v", timeouts)
+	}
+
+	// the default ping interval does not fit a shorter pong wait
+	timeouts = newConnTimeouts(config.WebsocketConfig{PongWaitSeconds: 10})
+	if timeouts.pingInterval != 9*time.Second {
+		t.Fatalf("ping interval %v for a 10s pong wait", timeouts.pingInterval)
+	}
+}
+
+func TestConnTimeoutsReadLimitAboveEnvelopeSize(t *testing.T) {
+	// oversized envelopes up to the read limit get an error frame instead of closing the connection
+	for _, maxMessageSize := range []int64{0, constants.MaxEnvelopeSize} {
+		timeouts := newConnTimeouts(config.WebsocketConfig{MaxMessageSize: maxMessageSize})
+		if timeouts.maxMessageSize != constants.MaxFrameSize {
+			t.Fatalf("read limit %d for a configured %d", timeouts.maxMessageSize, maxMessageSize)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/config"
+	"chating_service/internal/constants"
+)
+
+// connTimeouts are the liveness settings of the connections of a hub
+type connTimeouts struct {
+	pingInterval   time.Duration
+	pongWait       time.Duration
+	writeWait      time.Duration
+	idleTimeout    time.Duration // 0 keeps idle connections open
+	maxMessageSize int64
+}
+
+func newConnTimeouts(wsConfig config.WebsocketConfig) connTimeouts {
+	timeouts := connTimeouts{
+		pingInterval:   time.Duration(wsConfig.PingIntervalSeconds) * time.Second,
+		pongWait:       time.Duration(wsConfig.PongWaitSeconds) * time.Second,
+		writeWait:      time.Duration(wsConfig.WriteWaitSeconds) * time.Second,
+		idleTimeout:    time.Duration(wsConfig.IdleTimeoutSeconds) * time.Second,
+		maxMessageSize: wsConfig.MaxMessageSize,
+	}
+
+	if timeouts.pongWait <= 0 {
+		timeouts.pongWait = constants.PongWait
+	}
+	if timeouts.pingInterval <= 0 {
+		timeouts.pingInterval = constants.PingInterval
+		// the default only fits the default pong wait
+		if timeouts.pingInterval >= timeouts.pongWait {
+			timeouts.pingInterval = timeouts.pongWait * 9 / 10
+		}
+	}
+	if timeouts.pingInterval >= timeouts.pongWait {
+		log.Warn().Msgf("Websocket ping interval %v is not shorter than the pong wait %v", timeouts.pingInterval, timeouts.pongWait)
+		timeouts.pingInterval = timeouts.pongWait * 9 / 10
+	}
+	if timeouts.writeWait <= 0 {
+		timeouts.writeWait = constants.WriteWait
+	}
+	if wsConfig.IdleTimeoutSeconds == 0 {
+		timeouts.idleTimeout = constants.IdleTimeout
+	} else if timeouts.idleTimeout < 0 {
+		timeouts.idleTimeout = 0
+	}
+	if timeouts.maxMessageSize <= 0 {
+		timeouts.maxMessageSize = constants.MaxFrameSize
+	}
+	if timeouts.maxMessageSize <= constants.MaxEnvelopeSize {
+		log.Warn().Msgf("Websocket read limit %d is not above the envelope size %d", timeouts.maxMessageSize, constants.MaxEnvelopeSize)
+		timeouts.maxMessageSize = constants.MaxFrameSize
+	}
+	return timeouts
+}
+
+// connDeadline moves the read deadline of a connection. Pongs put it off by the pong wait,
+// but never past the idle timeout counted from the last frame of the client.
+// It is only used by the goroutine reading the connection, pong handlers run there too.
+type connDeadline struct {
+	conn       *websocket.Conn
+	timeouts   connTimeouts
+	lastActive time.Time
+	// onPong, if set, runs on every pong, e.g. to keep the presence of a quiet client alive
+	onPong func()
+}
+
+// watch applies the read limit and deadlines of the hub to a new connection
+func (t connTimeouts) watch(conn *websocket.Conn) *connDeadline {
+	deadline := &connDeadline{conn: conn, timeouts: t, lastActive: time.Now()}
+	conn.SetReadLimit(t.maxMessageSize)
+	conn.SetPongHandler(func(string) error {
+		if deadline.onPong != nil {
+			deadline.onPong()
+		}
+		return deadline.extend()
+	})
+	deadline.extend()
+	return deadline
+}
+
+// extend puts the read deadline off by the pong wait
+func (d *connDeadline) extend() error {
+	next := time.Now().Add(d.timeouts.pongWait)
+	if d.timeouts.idleTimeout > 0 {
+		if idle := d.lastActive.Add(d.timeouts.idleTimeout); idle.Before(next) {
+			next = idle
+		}
+	}
+	return d.conn.SetReadDeadline(next)
+}
+
+// active records a frame from the client
+func (d *connDeadline) active() {
+	d.lastActive = time.Now()
+	d.extend()
+}
+
+// idle tells whether the client sent nothing for the idle timeout
+func (d *connDeadline) idle() bool {
+	return d.timeouts.idleTimeout > 0 && time.Since(d.lastActive) >= d.timeouts.idleTimeout
+}
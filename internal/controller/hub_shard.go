@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/pubsub"
+)
+
+// group is the set of local clients of a room or an account. Broadcasts read it without
+// locking: members is an immutable snapshot, replaced under the lock of the shard on change.
+type group struct {
+	members atomic.Pointer[[]*client]
+}
+
+func (g *group) snapshot() []*client {
+	if members := g.members.Load(); members != nil {
+		return *members
+	}
+	return nil
+}
+
+// add stores a snapshot with the client appended. The caller must hold the lock of the shard.
+func (g *group) add(c *client) {
+	old := g.snapshot()
+	members := make([]*client, len(old), len(old)+1)
+	copy(members, old)
+	members = append(members, c)
+	g.members.Store(&members)
+}
+
+// remove stores a snapshot without the client. It returns whether the client was a member,
+// and whether it was the last one. The caller must hold the lock of the shard.
+func (g *group) remove(c *client) (bool, bool) {
+	old := g.snapshot()
+	members := make([]*client, 0, len(old))
+	for _, member := range old {
+		if member != c {
+			members = append(members, member)
+		}
+	}
+	if len(members) == len(old) {
+		return false, false
+	}
+	g.members.Store(&members)
+	return true, len(members) == 0
+}
+
+// hubShard holds the groups of a part of the rooms and accounts and fans out their broadcasts
+// on its own goroutine. Broadcasts of a room always go through the same shard, in order.
+type hubShard struct {
+	mu     sync.Mutex // serializes the membership changes of the shard
+	rooms  sync.Map   // room id → *group
+	users  sync.Map   // account id → *group
+	inbox  chan Message
+	broker pubsub.Broker
+}
+
+func newHubShard(broker pubsub.Broker) *hubShard {
+	return &hubShard{
+		inbox:  make(chan Message, constants.ShardInboxSize),
+		broker: broker,
+	}
+}
+
+func shardIndex(key string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return int(hash.Sum32() % constants.HubShardCount)
+}
+
+func (h *Hub) roomShard(roomId string) *hubShard {
+	return h.shards[shardIndex(roomId)]
+}
+
+func (h *Hub) userShard(accountId int64) *hubShard {
+	return h.shards[int(uint64(accountId)%constants.HubShardCount)]
+}
+
+// run fans out the broadcasts of the shard until its inbox is closed
+func (s *hubShard) run(h *Hub) {
+	for message := range s.inbox {
+		h.fanOut(message)
+	}
+}
+
+// joinRoom adds a client to the group of its room, subscribing to the room topic with its first client
+func (s *hubShard) joinRoom(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.rooms.Load(c.roomId)
+	if !ok {
+		value = &group{}
+		s.rooms.Store(c.roomId, value)
+		s.subscribe(pubsub.RoomTopic(c.roomId))
+	}
+	value.(*group).add(c)
+}
+
+// leaveRoom removes a client from the group of its room, unsubscribing from the room topic
+// with its last client. It returns false if the client was not a member.
+func (s *hubShard) leaveRoom(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, ok := s.rooms.Load(c.roomId)
+	if !ok {
+		return false
+	}
+	removed, empty := value.(*group).remove(c)
+	if empty {
+		s.rooms.Delete(c.roomId)
+		s.unsubscribe(pubsub.RoomTopic(c.roomId))
+	}
+	return removed
+}
+
+// roomMembers returns the local clients of the room
+func (s *hubShard) roomMembers(roomId string) []*client {
+	value, ok := s.rooms.Load(roomId)
+	if !ok {
+		return nil
+	}
+	return value.(*group).snapshot()
+}
+
+// roomClients returns the clients of every room of the shard
+func (s *hubShard) roomClients() []*client {
+	clients := []*client{}
+	s.rooms.Range(func(key, value interface{}) bool {
+		clients = append(clients, value.(*group).snapshot()...)
+		return true
+	})
+	return clients
+}
+
+// joinUser adds a client to the group of its account, subscribing to the user topic with its first client
+func (s *hubShard) joinUser(c *client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accountId := c.sender.AccountId
+	value, ok := s.users.Load(accountId)
+	if !ok {
+		value = &group{}
+		s.users.Store(accountId, value)
+		s.subscribe(pubsub.UserTopic(accountId))
+	}
+	value.(*group).add(c)
+}
+
+// leaveUser removes a client from the group of its account, unsubscribing from the user topic
+// with its last client. It returns false if the client was not a member.
+func (s *hubShard) leaveUser(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accountId := c.sender.AccountId
+	value, ok := s.users.Load(accountId)
+	if !ok {
+		return false
+	}
+	removed, empty := value.(*group).remove(c)
+	if empty {
+		s.users.Delete(accountId)
+		s.unsubscribe(pubsub.UserTopic(accountId))
+	}
+	return removed
+}
+
+// userMembers returns the local clients of the account
+func (s *hubShard) userMembers(accountId int64) []*client {
+	value, ok := s.users.Load(accountId)
+	if !ok {
+		return nil
+	}
+	return value.(*group).snapshot()
+}
+
+func (s *hubShard) subscribe(topic string) {
+	err := s.broker.Subscribe(context.Background(), topic)
+	if err != nil {
+		log.Err(err).Msgf("Failed to subscribe : %s", topic)
+	}
+}
+
+func (s *hubShard) unsubscribe(topic string) {
+	err := s.broker.Unsubscribe(context.Background(), topic)
+	if err != nil {
+		log.Err(err).Msgf("Failed to unsubscribe : %s", topic)
+	}
+}
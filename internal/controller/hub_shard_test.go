@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chating_service/internal/model"
+	"chating_service/internal/pubsub"
+)
+
+// addTestClient registers a client without a websocket and counts the frames it receives
+// until it is unregistered. The returned channel is closed once its queue is drained.
+func addTestClient(hub *Hub, roomId string, accountId int64, received *atomic.Int64, frames *[]string) (*client, chan struct{}) {
+	c := newClient(Subscription{roomId: roomId, sender: model.Sender{AccountId: accountId}}, &hub.queue)
+	hub.add(c)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for data := range c.send {
+			if frames != nil {
+				*frames = append(*frames, string(data))
+			}
+			received.Add(1)
+		}
+	}()
+	return c, done
+}
+
+func waitForCount(t testing.TB, counter *atomic.Int64, count int64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for counter.Load() < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d frames, want %d", counter.Load(), count)
+		}
+		time.Sleep(50 * time.Microsecond)
+	}
+}
+
+// TestHubConcurrentMembership churns the members of rooms while their broadcasts are fanned
+// out; run it with -race. The members staying in the rooms must get every broadcast in order.
+func TestHubConcurrentMembership(t *testing.T) {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+
+	const rooms = 8
+	const messages = 300
+	observed := make([]*atomic.Int64, rooms)
+	frames := make([][]string, rooms)
+	observerDone := make([]chan struct{}, rooms)
+	observers := make([]*client, rooms)
+	for i := 0; i < rooms; i++ {
+		observed[i] = &atomic.Int64{}
+		observers[i], observerDone[i] = addTestClient(hub, fmt.Sprintf("room-%d", i), 1, observed[i], &frames[i])
+	}
+
+	var churn sync.WaitGroup
+	for worker := 0; worker < 32; worker++ {
+		churn.Add(1)
+		go func(worker int) {
+			defer churn.Done()
+			var received atomic.Int64
+			for i := 0; i < 20; i++ {
+				c, done := addTestClient(hub, fmt.Sprintf("room-%d", (worker+i)%rooms), int64(100+worker), &received, nil)
+				time.Sleep(time.Millisecond)
+				hub.unregister(c)
+				<-done
+			}
+		}(worker)
+	}
+
+	for i := 1; i <= messages; i++ {
+		for room := 0; room < rooms; room++ {
+			if err := hub.publish(fmt.Sprintf("room-%d", room), []byte(fmt.Sprintf("%d", i))); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+		if i%50 == 0 {
+			for room := 0; room < rooms; room++ {
+				waitForCount(t, observed[room], int64(i))
+			}
+		}
+	}
+	churn.Wait()
+
+	for room := 0; room < rooms; room++ {
+		if members := hub.roomMembers(fmt.Sprintf("room-%d", room)); len(members) != 1 || members[0] != observers[room] {
+			t.Fatalf("room-%d has %d members after the churn", room, len(members))
+		}
+		hub.unregister(observers[room])
+		<-observerDone[room]
+		for i, frame := range frames[room] {
+			if frame != fmt.Sprintf("%d", i+1) {
+				t.Fatalf("room-%d frame %d is %s", room, i, frame)
+			}
+		}
+		if len(frames[room]) != messages {
+			t.Fatalf("room-%d received %d frames, want %d", room, len(frames[room]), messages)
+		}
+		if members := hub.roomMembers(fmt.Sprintf("room-%d", room)); len(members) != 0 {
+			t.Fatalf("room-%d still has %d members", room, len(members))
+		}
+	}
+}
+
+// TestHubConcurrentUnregister unregisters the same client from several goroutines at once
+func TestHubConcurrentUnregister(t *testing.T) {
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	kept := make(chan []int64, 4)
+	hub.keepUnacked = func(subscription Subscription, messageIds []int64) {
+		kept <- messageIds
+	}
+
+	var received atomic.Int64
+	c, done := addTestClient(hub, "room-1", 1, &received, nil)
+	hub.deliver(c, []byte("message"), 7)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hub.unregister(c)
+		}()
+	}
+	wg.Wait()
+	<-done
+
+	select {
+	case messageIds := <-kept:
+		if len(messageIds) != 1 || messageIds[0] != 7 {
+			t.Fatalf("kept %v, want [7]", messageIds)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("unacked messages were not kept")
+	}
+	select {
+	case messageIds := <-kept:
+		t.Fatalf("unacked messages kept twice: %v", messageIds)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// BenchmarkHubFanOut measures the frames delivered per second with 10k connections spread
+// over 1k rooms, every broadcast reaching the 10 members of its room
+func BenchmarkHubFanOut(b *testing.B) {
+	const rooms = 1000
+	const membersPerRoom = 10
+	// broadcasts in flight at once, few enough per room for the send queues
+	const window = 5000
+
+	hub := newTestHub(pubsub.NewMemoryBus().NewBroker())
+	var received atomic.Int64
+	clients := make([]*client, 0, rooms*membersPerRoom)
+	for room := 0; room < rooms; room++ {
+		for member := 0; member < membersPerRoom; member++ {
+			c, _ := addTestClient(hub, fmt.Sprintf("room-%d", room), int64(room*membersPerRoom+member+1), &received, nil)
+			clients = append(clients, c)
+		}
+	}
+	data := []byte(`{"v":1,"type":"message","roomId":"room","payload":{"text":"hello"}}`)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := hub.publish(fmt.Sprintf("room-%d", i%rooms), data); err != nil {
+			b.Fatalf("publish: %v", err)
+		}
+		if (i+1)%window == 0 {
+			waitForCount(b, &received, int64(i+1)*membersPerRoom)
+		}
+	}
+	waitForCount(b, &received, int64(b.N)*membersPerRoom)
+	b.StopTimer()
+
+	b.ReportMetric(float64(received.Load())/b.Elapsed().Seconds(), "frames/s")
+	for _, c := range clients {
+		hub.unregister(c)
+	}
+}
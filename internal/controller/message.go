@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+	"chating_service/internal/utils"
+)
+
+// GetMessages returns the room history newest first.
+// Older pages are loaded by passing the returned nextCursor as "before".
+func GetMessages(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+
+	limit, ok := messagePageLimit(ctx)
+	if !ok {
+		return
+	}
+
+	page, err := service.GetMessages(localCtx, roomId, ctx.Query("before"), limit)
+	if err != nil {
+		messagePageFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, page)
+}
+
+// GetThreadReplies returns the replies to a message, newest first, paged like GetMessages
+func GetThreadReplies(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+	limit, ok := messagePageLimit(ctx)
+	if !ok {
+		return
+	}
+
+	page, err := service.GetThreadReplies(localCtx, roomId, messageId, ctx.Query("before"), limit)
+	if err != nil {
+		messagePageFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, page)
+}
+
+// messagePageLimit parses the limit query parameter, capped at the maximum page size
+func messagePageLimit(ctx *gin.Context) (int, bool) {
+	limit := constants.DefaultMessagePageSize
+	if limitParam := ctx.Query("limit"); limitParam != "" {
+		var err error
+		limit, err = strconv.Atoi(limitParam)
+		if err != nil || limit <= 0 {
+			FailureResponse(ctx, constants.InvalidInputData)
+			return 0, false
+		}
+	}
+	if limit > constants.MaxMessagePageSize {
+		limit = constants.MaxMessagePageSize
+	}
+	return limit, true
+}
+
+func messagePageFailureResponse(ctx *gin.Context, err error) {
+	if errors.Is(err, utils.ErrInvalidCursor) {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return
+	}
+	roomFailureResponse(ctx, err)
+}
+
+// messageIdParam parses the messageId path parameter
+func messageIdParam(ctx *gin.Context) (int64, bool) {
+	messageId, err := strconv.ParseInt(ctx.Param("messageId"), 10, 64)
+	if err != nil || messageId <= 0 {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return 0, false
+	}
+	return messageId, true
+}
+
+// publishRoomEvent broadcasts a server event about a message to the room
+func (h *Hub) publishRoomEvent(envelopeType string, roomId string, messageId int64, payload interface{}) {
+	envelope, err := newEnvelope(envelopeType, roomId, nil, payload)
+	if err != nil {
+		log.Error().Msgf("Failed to build %s envelope: %v", envelopeType, err)
+		return
+	}
+	envelope.Id = strconv.FormatInt(messageId, 10)
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		log.Error().Msgf("Failed to marshal %s envelope: %v", envelopeType, err)
+		return
+	}
+
+	err = h.publish(roomId, data)
+	if err != nil {
+		log.Error().Msgf("Failed to publish %s: %v", envelopeType, err)
+	}
+}
+
+func UpdateMessage(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	var form model.UpdateMessageForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	message, err := service.EditMessage(localCtx, roomId, messageId, form.Body)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	hub.publishRoomEvent(model.EnvelopeTypeMessageUpdated, roomId, messageId, model.MessageUpdatedPayload{
+		Text:       message.Body,
+		EditedBy:   localCtx.AccountId,
+		EditedAt:   *message.EditedAt,
+		Mentions:   message.Mentions,
+		MentionAll: message.MentionAll,
+	})
+	ResponseWithData(ctx, message)
+}
+
+func DeleteMessage(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	_, err := service.DeleteMessage(localCtx, roomId, messageId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	hub.publishRoomEvent(model.EnvelopeTypeMessageDeleted, roomId, messageId, model.MessageDeletedPayload{
+		DeletedBy: localCtx.AccountId,
+	})
+	SuccessResponse(ctx)
+}
+
+func GetMessageEdits(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	edits, err := service.GetMessageEdits(localCtx, ctx.Param("roomId"), messageId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, edits)
+}
@@ -0,0 +1,11 @@
+package controller
+
+import "expvar"
+
+// websocket metrics, served with the other expvars at /debug/vars of the metrics address
+var (
+	// frames dropped for connections that could not keep up, by room
+	droppedFrames = expvar.NewMap("websocket_dropped_frames")
+	// connections closed for not keeping up, by room
+	slowDisconnects = expvar.NewMap("websocket_slow_disconnects")
+)
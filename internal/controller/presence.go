@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+func GetRoomPresence(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	presences, err := service.GetRoomPresence(localCtx, ctx.Param("roomId"))
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, presences)
+}
+
+// connPresence is the presence of one websocket connection, kept by its readPump
+type connPresence struct {
+	hub          *Hub
+	localCtx     *model.LocalCtx
+	subscription Subscription
+	status       string
+	refreshedAt  time.Time
+}
+
+// connect marks the connection online and tells the room if the account just came online
+func (p *connPresence) connect() {
+	online, err := service.ConnectPresence(p.localCtx, p.subscription.roomId)
+	if err != nil {
+		log.Err(err).Msg("Failed to connect presence")
+		return
+	}
+
+	p.status = constants.PresenceOnline
+	p.refreshedAt = time.Now()
+	if online {
+		p.publish(constants.PresenceOnline)
+	}
+}
+
+// disconnect tells the room if the last connection of the account is gone
+func (p *connPresence) disconnect() {
+	offline, err := service.DisconnectPresence(p.localCtx, p.subscription.roomId)
+	if err != nil {
+		log.Err(err).Msg("Failed to disconnect presence")
+		return
+	}
+
+	if offline {
+		p.publish(constants.PresenceOffline)
+	}
+}
+
+// touch records client activity with the given status. The ttl is refreshed at most once per
+// PresenceRefreshInterval unless the status changes.
+func (p *connPresence) touch(status string) {
+	if status == p.status && time.Since(p.refreshedAt) < constants.PresenceRefreshInterval {
+		return
+	}
+
+	changed, err := service.UpdatePresence(p.localCtx, p.subscription.roomId, status)
+	if err != nil {
+		log.Err(err).Msg("Failed to update presence")
+		return
+	}
+
+	p.status = status
+	p.refreshedAt = time.Now()
+	if changed {
+		p.publish(status)
+	}
+}
+
+func (p *connPresence) publish(status string) {
+	sender := p.subscription.sender
+	payload := model.PresencePayload{AccountId: sender.AccountId, UserId: sender.UserId, Status: status}
+
+	envelope, err := newEnvelope(model.EnvelopeTypePresence, p.subscription.roomId, &sender, payload)
+	if err != nil {
+		log.Err(err).Msg("Failed to build presence envelope")
+		return
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		log.Err(err).Msg("Failed to marshal presence envelope")
+		return
+	}
+
+	err = p.hub.publish(p.subscription.roomId, data)
+	if err != nil {
+		log.Err(err).Msg("Failed to publish presence")
+	}
+}
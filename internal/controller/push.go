@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// pushJob is a saved message whose offline recipients still have to be notified
+type pushJob struct {
+	sender    model.Sender
+	message   model.Message
+	mentioned []int64
+}
+
+// queuePush hands a message to the push worker without waiting for it.
+// The job is dropped if the worker is too far behind.
+func (h *Hub) queuePush(job pushJob) {
+	select {
+	case h.pushes <- job:
+	default:
+		log.Warn().Msgf("Push queue full, dropped notifications of message : %d", job.message.Id)
+	}
+}
+
+// runPushWorker sends the push notifications of the queued messages one at a time,
+// so a slow push gateway never holds up a readPump
+func (h *Hub) runPushWorker() {
+	for job := range h.pushes {
+		localCtx := newHubLocalCtx(job.sender.AccountId)
+		notifications, err := service.GetOfflineNotifications(localCtx, job.sender, job.message, job.mentioned)
+		if err != nil {
+			log.Err(err).Msgf("Failed to find offline members of room : %s", job.message.RoomId)
+			continue
+		}
+
+		for _, notification := range notifications {
+			ctx, cancel := context.WithTimeout(context.Background(), constants.PushTimeout)
+			err := h.notifier.Notify(ctx, notification)
+			cancel()
+			if err != nil {
+				log.Err(err).Msgf("Failed to push notification to account : %d", notification.AccountId)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+	"chating_service/internal/utils"
+)
+
+// connLimiter applies the rate limits to the frames of one websocket connection, kept by its readPump
+type connLimiter struct {
+	hub          *Hub
+	localCtx     *model.LocalCtx
+	subscription Subscription
+	connId       string
+	closing      bool // the connection is being closed for going over the limits
+}
+
+func newConnLimiter(hub *Hub, localCtx *model.LocalCtx, subscription Subscription) *connLimiter {
+	connId, err := utils.NewObjectId()
+	if err != nil {
+		log.Err(err).Msg("Failed to make a connection id, limiting the account in the room instead")
+		connId = subscription.roomId + "_" + strconv.FormatInt(subscription.sender.AccountId, 10)
+	}
+	return &connLimiter{hub: hub, localCtx: localCtx, subscription: subscription, connId: connId}
+}
+
+// allow takes the tokens of a frame, malformed frames included; parseErr is the error of
+// parsing it. A limited frame is answered with a rate_limited error frame, and the connection
+// of a repeat offender is closed. Valid typing indicators have a bucket of their own, so
+// typing never holds back messages; the typing throttle lets stop events through.
+// Valid acks are never limited. When redis is unavailable frames are let through.
+func (l *connLimiter) allow(envelope model.Envelope, parseErr error) bool {
+	if l.closing {
+		return false
+	}
+	if parseErr == nil && envelope.Type == model.EnvelopeTypeAck {
+		return true
+	}
+
+	roomId := l.subscription.roomId
+	var retryAfter time.Duration
+	var err error
+	if parseErr == nil && envelope.Type == model.EnvelopeTypeTyping {
+		retryAfter, err = service.AllowTypingFrame(l.localCtx, l.connId)
+	} else {
+		retryAfter, err = service.AllowFrame(l.localCtx, l.connId, roomId, parseErr == nil && envelope.Type == model.EnvelopeTypeMessage)
+	}
+	if err != nil {
+		log.Err(err).Msg("Failed to rate limit frame")
+		return true
+	}
+	if retryAfter == 0 {
+		return true
+	}
+
+	limited := &envelopeError{code: constants.RateLimited, message: "rate_limited", retryAfter: retryAfter}
+	l.hub.sendTo(l.subscription, errorFrame(roomId, envelope.Id, limited))
+
+	offender, err := service.RecordRateLimitStrike(l.localCtx)
+	if err != nil {
+		log.Err(err).Msg("Failed to record rate limit strike")
+	}
+	if offender {
+		log.Warn().Msgf("Disconnecting rate limited account. room : %s, account : %d", roomId, l.subscription.sender.AccountId)
+		// writePump sends the close frame after the error frame, frames read meanwhile are ignored
+		l.closing = true
+		l.subscription.client.closeWith(websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limited"))
+	}
+	return false
+}
+
+// LoginRateLimit limits the login attempts of a client address
+func LoginRateLimit() gin.HandlerFunc {
+	return rateLimitByClientIp(service.AllowLogin)
+}
+
+// RefreshTokenRateLimit limits the token refreshes of a client address
+func RefreshTokenRateLimit() gin.HandlerFunc {
+	return rateLimitByClientIp(service.AllowRefreshToken)
+}
+
+// rateLimitByClientIp answers 429 with a Retry-After header to the requests of a client
+// address over its limit. When redis is unavailable requests are let through.
+func rateLimitByClientIp(allow func(localCtx *model.LocalCtx, clientIp string) (time.Duration, error)) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		retryAfter, err := allow(getLocalCtx(ginCtx), ginCtx.ClientIP())
+		if err != nil {
+			log.Err(err).Msgf("Failed to rate limit %s", ginCtx.FullPath())
+			return
+		}
+		if retryAfter == 0 {
+			return
+		}
+
+		log.Warn().Msgf("Rate limited %s from %s", ginCtx.FullPath(), ginCtx.ClientIP())
+		rateLimitedResponse(ginCtx, retryAfter)
+	}
+}
+
+// allowLoginUser limits the login attempts to a user id, so spreading the attempts over many
+// addresses does not help guessing its password. When redis is unavailable logins are let through.
+func allowLoginUser(ginCtx *gin.Context, localCtx *model.LocalCtx, userId string) bool {
+	retryAfter, err := service.AllowLoginUser(localCtx, userId)
+	if err != nil {
+		log.Err(err).Msg("Failed to rate limit login")
+		return true
+	}
+	if retryAfter == 0 {
+		return true
+	}
+
+	log.Warn().Msgf("Rate limited login of %s from %s", userId, ginCtx.ClientIP())
+	rateLimitedResponse(ginCtx, retryAfter)
+	return false
+}
+
+// rateLimitedResponse answers 429 with a Retry-After header
+func rateLimitedResponse(ginCtx *gin.Context, retryAfter time.Duration) {
+	ginCtx.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	ginCtx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate_limited", "retryAfterMs": retryAfter.Milliseconds()})
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+func TestErrorFrameCarriesRetryAfter(t *testing.T) {
+	limited := &envelopeError{code: constants.RateLimited, message: "rate_limited", retryAfter: 1500 * time.Millisecond}
+
+	var envelope model.Envelope
+	if err := json.Unmarshal(errorFrame("room-1", "c1", limited), &envelope); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var payload model.ErrorPayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if envelope.Id != "c1" || payload.Code != constants.RateLimited || payload.Message != "rate_limited" || payload.RetryAfterMs != 1500 {
+		t.Fatalf("unexpected error frame %s: %+v", envelope.Id, payload)
+	}
+}
+
+// TestAllowFrameConnectionBucket runs against a local redis-server, e.g. REDIS_ADDR=localhost:6379
+func TestAllowFrameConnectionBucket(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	rds := redis.NewClient(&redis.Options{Addr: addr})
+	defer rds.Close()
+	localCtx := &model.LocalCtx{AccountId: 1, RedisCtx: &db.RedisCtx{Rds: rds, Ctx: context.Background()}}
+
+	connId := "test_" + time.Now().Format(time.RFC3339Nano)
+	for i := 0; i < constants.ConnectionFrameBurst; i++ {
+		retryAfter, err := service.AllowFrame(localCtx, connId, "room-1", false)
+		if err != nil {
+			t.Fatalf("allow: %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("frame %d of the burst was limited", i)
+		}
+	}
+
+	retryAfter, err := service.AllowFrame(localCtx, connId, "room-1", false)
+	if err != nil {
+		t.Fatalf("allow: %v", err)
+	}
+	if retryAfter <= 0 || retryAfter > constants.ConnectionFrameRefill {
+		t.Fatalf("frame over the burst got retry after %v", retryAfter)
+	}
+
+	time.Sleep(retryAfter)
+	if retryAfter, err := service.AllowFrame(localCtx, connId, "room-1", false); err != nil || retryAfter != 0 {
+		t.Fatalf("frame after the refill was limited: %v, %v", retryAfter, err)
+	}
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+func AddReaction(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	var form model.ReactionForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	change, err := service.AddReaction(localCtx, roomId, messageId, form.Emoji)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	if change != nil {
+		hub.publishRoomEvent(model.EnvelopeTypeReactionAdded, roomId, messageId, change)
+	}
+	SuccessResponse(ctx)
+}
+
+func RemoveReaction(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+	messageId, ok := messageIdParam(ctx)
+	if !ok {
+		return
+	}
+
+	change, err := service.RemoveReaction(localCtx, roomId, messageId, ctx.Param("emoji"))
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	if change != nil {
+		hub.publishRoomEvent(model.EnvelopeTypeReactionRemoved, roomId, messageId, change)
+	}
+	SuccessResponse(ctx)
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// handleRead stores the read marker of the sender and broadcasts it to the room
+func (h *Hub) handleRead(localCtx *model.LocalCtx, subscription Subscription, envelope model.Envelope) error {
+	var payload model.ReadPayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		return err
+	}
+
+	moved, err := service.MarkRead(localCtx, subscription.roomId, payload.MessageId)
+	if err != nil {
+		return err
+	}
+	if !moved {
+		return nil
+	}
+
+	out, err := newEnvelope(model.EnvelopeTypeRead, subscription.roomId, &subscription.sender, payload)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return h.publish(subscription.roomId, data)
+}
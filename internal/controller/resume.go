@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// handleResume replays the messages of the room after the last sequence number the client saw.
+// Live frames for the connection are kept back meanwhile and follow the replay, without the
+// messages it already contained.
+func (h *Hub) handleResume(localCtx *model.LocalCtx, subscription Subscription, envelope model.Envelope) error {
+	var payload model.ResumePayload
+	if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+		return err
+	}
+
+	subscription.client.hold()
+	frames, lastSeq, truncated, err := replayFrames(localCtx, subscription.roomId, payload.LastSeq)
+	if err != nil {
+		h.release(subscription.client, nil, 0)
+		return err
+	}
+
+	done, err := newEnvelope(model.EnvelopeTypeResume, subscription.roomId, nil, model.ResumePayload{LastSeq: lastSeq, Truncated: truncated})
+	if err != nil {
+		h.release(subscription.client, nil, 0)
+		return err
+	}
+	data, err := json.Marshal(done)
+	if err != nil {
+		h.release(subscription.client, nil, 0)
+		return err
+	}
+
+	h.release(subscription.client, append(frames, data), lastSeq)
+	return nil
+}
+
+// replayFrames builds the message frames of the room after afterSeq and returns them with the
+// sequence number of the last one. Deleted messages are skipped.
+func replayFrames(localCtx *model.LocalCtx, roomId string, afterSeq int64) ([][]byte, int64, bool, error) {
+	messages, truncated, err := service.GetMessagesAfterSeq(localCtx, roomId, afterSeq, constants.MaxResumeMessages)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	accountIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		accountIds = append(accountIds, message.AccountId)
+	}
+	userIds, err := service.GetUserIds(localCtx, accountIds)
+	if err != nil {
+		return nil, 0, false, err
+	}
+
+	lastSeq := afterSeq
+	frames := make([][]byte, 0, len(messages))
+	for _, message := range messages {
+		lastSeq = message.Seq
+		if message.Deleted {
+			continue
+		}
+
+		sender := model.Sender{AccountId: message.AccountId, UserId: userIds[message.AccountId]}
+		out, err := messageEnvelope(sender, message)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		frames = append(frames, data)
+	}
+	return frames, lastSeq, truncated, nil
+}
+
+// release ends the resume of a client, dropping it if it can not keep up
+func (h *Hub) release(c *client, replay [][]byte, lastSeq int64) {
+	if !c.release(replay, lastSeq) {
+		h.drop(c)
+	}
+}
+
+// isReplayedMessage tells whether a frame is a message of the room up to lastSeq
+func isReplayedMessage(data []byte, roomId string, lastSeq int64) bool {
+	var frame struct {
+		Type   string `json:"type"`
+		RoomId string `json:"roomId"`
+		Seq    int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return false
+	}
+	return frame.Type == model.EnvelopeTypeMessage && frame.RoomId == roomId && frame.Seq > 0 && frame.Seq <= lastSeq
+}
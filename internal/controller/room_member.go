@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// roomErrorCode maps a room service error to its response code
+func roomErrorCode(err error) int {
+	switch {
+	case errors.Is(err, service.ErrRoomNotFound), errors.Is(err, service.ErrAccountNotFound),
+		errors.Is(err, service.ErrMessageNotFound), errors.Is(err, service.ErrAttachmentNotFound):
+		return constants.NotExistItem
+	case errors.Is(err, service.ErrInvalidFileSize):
+		return constants.InvalidFileSize
+	case errors.Is(err, service.ErrInvalidFileExtension):
+		return constants.InvalidFileExtension
+	case errors.Is(err, service.ErrInvalidFileCheckSum):
+		return constants.InvalidFileCheckSum
+	case errors.Is(err, service.ErrTooManyAttachments):
+		return constants.ExceedMaxCount
+	case errors.Is(err, service.ErrNotRoomMember):
+		return constants.NotRoomMember
+	case errors.Is(err, service.ErrPermissionDenied):
+		return constants.PermissionDenied
+	case errors.Is(err, service.ErrAlreadyMember):
+		return constants.AlreadyMember
+	case errors.Is(err, service.ErrDirectMessageToSelf), errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidThreadParent), errors.Is(err, service.ErrTooManyMentions),
+		errors.Is(err, service.ErrInvalidFileName), errors.Is(err, service.ErrInvalidRole):
+		return constants.InvalidInputData
+	case errors.Is(err, service.ErrInvalidRoomName), errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrEmptySearch):
+		return constants.CheckRequiredItems
+	case errors.Is(err, service.ErrRoomNameTooLong), errors.Is(err, service.ErrRoomDescriptionTooLong),
+		errors.Is(err, service.ErrMessageTooLong):
+		return constants.ExceedMaxLength
+	default:
+		return constants.ServerInternalError
+	}
+}
+
+// roomErrorStatus maps a room service error to the http status of a rejected websocket upgrade
+func roomErrorStatus(err error) int {
+	switch roomErrorCode(err) {
+	case constants.NotExistItem:
+		return http.StatusNotFound
+	case constants.NotRoomMember, constants.PermissionDenied:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func roomFailureResponse(ctx *gin.Context, err error) {
+	code := roomErrorCode(err)
+	if code == constants.ServerInternalError {
+		log.Error().Msgf("Room request failed: %v", err)
+	}
+	FailureResponse(ctx, code)
+}
+
+func GetRoomMembers(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	members, err := service.GetRoomMembers(localCtx, ctx.Param("roomId"))
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, members)
+}
+
+func JoinRoom(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	err := service.JoinRoom(localCtx, ctx.Param("roomId"))
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	SuccessResponse(ctx)
+}
+
+func LeaveRoom(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+	err := service.LeaveRoom(localCtx, roomId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	err = hub.disconnectMember(roomId, localCtx.AccountId)
+	if err != nil {
+		log.Error().Msgf("Failed to disconnect member: %v", err)
+	}
+	SuccessResponse(ctx)
+}
+
+func InviteMember(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	var form model.RoomMemberForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	err := service.InviteMember(localCtx, ctx.Param("roomId"), form.AccountId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	SuccessResponse(ctx)
+}
+
+func KickMember(hub *Hub, ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+	roomId := ctx.Param("roomId")
+
+	var form model.RoomMemberForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	err := service.KickMember(localCtx, roomId, form.AccountId)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	err = hub.disconnectMember(roomId, form.AccountId)
+	if err != nil {
+		log.Error().Msgf("Failed to disconnect member: %v", err)
+	}
+	SuccessResponse(ctx)
+}
+
+func ChangeMemberRole(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	accountId, err := strconv.ParseInt(ctx.Param("accountId"), 10, 64)
+	if err != nil || accountId <= 0 {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return
+	}
+	var form model.RoomMemberRoleForm
+	if err := ctx.ShouldBindJSON(&form); err != nil {
+		FailureResponse(ctx, constants.CheckRequiredItems)
+		return
+	}
+
+	err = service.ChangeMemberRole(localCtx, ctx.Param("roomId"), accountId, form.Role)
+	if err != nil {
+		roomFailureResponse(ctx, err)
+		return
+	}
+
+	SuccessResponse(ctx)
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// SearchMessages searches the rooms the caller is a member of.
+// q is the search text, room narrows the search to one room and from/before bound the
+// message time, as a date (2006-01-02) or an RFC 3339 time. cursor pages like GetMessages.
+func SearchMessages(ctx *gin.Context) {
+	localCtx := getLocalCtx(ctx)
+
+	limit, ok := messagePageLimit(ctx)
+	if !ok {
+		return
+	}
+
+	form := model.SearchForm{
+		Text:   ctx.Query("q"),
+		RoomId: ctx.Query("room"),
+		Cursor: ctx.Query("cursor"),
+		Limit:  limit,
+	}
+	var err error
+	form.From, err = searchTimeParam(ctx.Query("from"))
+	if err != nil {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return
+	}
+	form.Before, err = searchTimeParam(ctx.Query("before"))
+	if err != nil {
+		FailureResponse(ctx, constants.InvalidInputData)
+		return
+	}
+
+	page, err := service.SearchMessages(localCtx, form)
+	if err != nil {
+		messagePageFailureResponse(ctx, err)
+		return
+	}
+
+	ResponseWithData(ctx, page)
+}
+
+// searchTimeParam parses a date or an RFC 3339 time. An empty value is the zero time.
+func searchTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/config"
+	"chating_service/internal/constants"
+)
+
+// queuePolicy is how the clients of a hub queue their outbound frames
+type queuePolicy struct {
+	size         int
+	slowConsumer string
+	closeCode    int
+}
+
+func newQueuePolicy(wsConfig config.WebsocketConfig) queuePolicy {
+	policy := queuePolicy{
+		size:         wsConfig.SendBufferSize,
+		slowConsumer: wsConfig.SlowConsumer,
+		closeCode:    wsConfig.SlowConsumerCloseCode,
+	}
+
+	if policy.size == 0 {
+		policy.size = constants.SendBufferSize
+	} else if policy.size < constants.MinSendBufferSize {
+		log.Warn().Msgf("Websocket send buffer of %d frames can not hold a resume, using %d", policy.size, constants.MinSendBufferSize)
+		policy.size = constants.MinSendBufferSize
+	}
+
+	switch policy.slowConsumer {
+	case constants.SlowConsumerDisconnect, constants.SlowConsumerDropOldest, constants.SlowConsumerDropNewest:
+	case "":
+		policy.slowConsumer = constants.SlowConsumerDisconnect
+	default:
+		log.Warn().Msgf("Unknown slow consumer policy %q, using %s", policy.slowConsumer, constants.SlowConsumerDisconnect)
+		policy.slowConsumer = constants.SlowConsumerDisconnect
+	}
+
+	if policy.closeCode == 0 {
+		policy.closeCode = constants.SlowConsumerCloseCode
+	}
+	return policy
+}
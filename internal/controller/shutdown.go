@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+)
+
+// reconnectHint is the reason of the going away close frame sent on shutdown
+type reconnectHint struct {
+	Reconnect    bool  `json:"reconnect"`
+	RetryAfterMs int64 `json:"retryAfterMs"`
+}
+
+// goingAwayFrame builds the close frame telling a client to reconnect after a random delay
+func goingAwayFrame() []byte {
+	hint, _ := json.Marshal(reconnectHint{
+		Reconnect:    true,
+		RetryAfterMs: rand.Int63n(constants.ReconnectJitter.Milliseconds()),
+	})
+	return websocket.FormatCloseMessage(websocket.CloseGoingAway, string(hint))
+}
+
+// Shutdown stops accepting connections and closes every connection with a going away frame
+// once its queued frames are written. It waits for the frames in progress to be handled,
+// the queued push notifications to be sent and the unacked messages to be kept, then closes
+// the broker. Connections still open when ctx ends are cut.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.lifecycle.Lock()
+	h.closing.Store(true)
+	h.lifecycle.Unlock()
+
+	clients := h.clients()
+	log.Info().Msgf("Closing %d websocket connections", len(clients))
+	for _, c := range clients {
+		c.closeWith(goingAwayFrame())
+	}
+
+	err := waitGroup(ctx, &h.serving)
+	if err != nil {
+		for _, c := range h.clients() {
+			c.conn.Close()
+		}
+		return err
+	}
+
+	// no readPump is left to queue a push
+	close(h.pushes)
+	err = waitGroup(ctx, &h.background)
+	if err != nil {
+		return err
+	}
+	return h.broker.Close()
+}
+
+// clients returns every registered client
+func (h *Hub) clients() []*client {
+	clients := []*client{}
+	for _, shard := range h.shards {
+		clients = append(clients, shard.roomClients()...)
+	}
+	return clients
+}
+
+// waitGroup waits for the group until ctx ends
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/service"
+)
+
+// handleTyping relays a typing indicator to the other members of the room.
+// Indicators are never stored and throttled events are dropped silently.
+func (h *Hub) handleTyping(localCtx *model.LocalCtx, subscription Subscription, envelope model.Envelope) {
+	var payload model.TypingPayload
+	json.Unmarshal(envelope.Payload, &payload)
+
+	allowed, err := service.AllowTyping(localCtx, subscription.roomId, payload.Typing)
+	if err != nil {
+		log.Err(err).Msg("Failed to throttle typing")
+		return
+	}
+	if !allowed {
+		return
+	}
+
+	if payload.Typing {
+		payload.ExpiresInMs = constants.TypingExpire.Milliseconds()
+	}
+
+	out, err := newEnvelope(model.EnvelopeTypeTyping, subscription.roomId, &subscription.sender, payload)
+	if err != nil {
+		log.Err(err).Msg("Failed to build typing envelope")
+		return
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		log.Err(err).Msg("Failed to marshal typing envelope")
+		return
+	}
+
+	err = h.publishExcept(subscription.roomId, subscription.sender.AccountId, data)
+	if err != nil {
+		log.Err(err).Msg("Failed to publish typing")
+	}
+}
@@ -32,6 +32,7 @@ func InitDbConnection(appConfig *config.AppConfig) {
 		Addr:                 dbAddress,
 		DBName:               appConfig.Rdb.DbName,
 		AllowNativePasswords: true,
+		ParseTime:            true,
 	}
 
 	dbPool, _ = sql.Open(appConfig.Rdb.Driver, cfg.FormatDSN())
@@ -58,6 +59,17 @@ func GetDbConnection(ctx context.Context) DbCtx {
 	}
 }
 
+// CloseDbConnection closes the pool once the queries in progress are done
+func CloseDbConnection() {
+	if dbPool == nil {
+		return
+	}
+	err := dbPool.Close()
+	if err != nil {
+		log.Error().Msg("CloseDbConnection:: error closing the database. " + err.Error())
+	}
+}
+
 func InitTestDbConnection(appConfig *config.AppConfig, connStr string) {
 	dbPool, _ = sql.Open(appConfig.Rdb.Driver, connStr)
 
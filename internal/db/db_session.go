@@ -15,6 +15,14 @@ func (s *DbCtx) CreatePrepareStmt(sql string) (*sql.Stmt, error) {
 	return s.DB.PrepareContext(s.Ctx, sql)
 }
 
+// ExecContext runs the statement in the current transaction if one was started by BeginTxn
+func (s *DbCtx) ExecContext(query string, args ...interface{}) (sql.Result, error) {
+	if s.Tx != nil {
+		return s.Tx.ExecContext(s.Ctx, query, args...)
+	}
+	return s.DB.ExecContext(s.Ctx, query, args...)
+}
+
 func (s *DbCtx) Rollback() error {
 	if s.Tx != nil {
 		return s.Tx.Rollback()
@@ -39,3 +47,23 @@ func (s *DbCtx) BeginTxn() error {
 	s.Tx = tx
 	return nil
 }
+
+// RunInTxn runs fn in a transaction. Statements made through ExecContext inside fn join it.
+// The transaction is rolled back if fn returns an error.
+func (s *DbCtx) RunInTxn(fn func() error) error {
+	err := s.BeginTxn()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		s.Tx = nil
+	}()
+
+	err = fn()
+	if err != nil {
+		s.Rollback()
+		return err
+	}
+
+	return s.Commit()
+}
@@ -39,3 +39,18 @@ func GetRedisConnection(ctx context.Context) RedisCtx {
 		Ctx: ctx,
 	}
 }
+
+func CloseRedisConnection() {
+	if rds == nil {
+		return
+	}
+	err := rds.Close()
+	if err != nil {
+		log.Error().Msg("CloseRedisConnection:: error closing the redis. " + err.Error())
+	}
+}
+
+// GetRedisClient returns the shared redis client for work that needs the raw client, such as pub/sub
+func GetRedisClient() *redis.Client {
+	return rds
+}
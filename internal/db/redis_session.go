@@ -27,6 +27,57 @@ func (s *RedisCtx) Del(key string) error {
 	return s.Rds.Del(s.Ctx, key).Err()
 }
 
+// SetNX sets the key only if it does not exist and returns whether it was set
+func (s *RedisCtx) SetNX(key string, value string, expiration time.Duration) (bool, error) {
+	return s.Rds.SetNX(s.Ctx, key, value, expiration).Result()
+}
+
+func (s *RedisCtx) Incr(key string) (int64, error) {
+	return s.Rds.Incr(s.Ctx, key).Result()
+}
+
+func (s *RedisCtx) Decr(key string) (int64, error) {
+	return s.Rds.Decr(s.Ctx, key).Result()
+}
+
+func (s *RedisCtx) Expire(key string, expiration time.Duration) error {
+	return s.Rds.Expire(s.Ctx, key, expiration).Err()
+}
+
+func (s *RedisCtx) MGet(keys ...string) ([]interface{}, error) {
+	return s.Rds.MGet(s.Ctx, keys...).Result()
+}
+
+func (s *RedisCtx) ZAdd(key string, score float64, member string) error {
+	return s.Rds.ZAdd(s.Ctx, key, redis.Z{Score: score, Member: member}).Err()
+}
+
+func (s *RedisCtx) ZRem(key string, member string) error {
+	return s.Rds.ZRem(s.Ctx, key, member).Err()
+}
+
+// ZRangeByScore returns the members with a score between min and max, e.g. "-inf" or "+inf"
+func (s *RedisCtx) ZRangeByScore(key string, min string, max string) ([]string, error) {
+	return s.Rds.ZRangeByScore(s.Ctx, key, &redis.ZRangeBy{Min: min, Max: max}).Result()
+}
+
+func (s *RedisCtx) ZRemRangeByScore(key string, min string, max string) error {
+	return s.Rds.ZRemRangeByScore(s.Ctx, key, min, max).Err()
+}
+
 func (s *RedisCtx) SetWithExpire(key string, value string, expiration time.Duration) error {
 	return s.Rds.Set(s.Ctx, key, value, expiration).Err()
 }
+
+func (s *RedisCtx) SAdd(key string, members ...interface{}) error {
+	return s.Rds.SAdd(s.Ctx, key, members...).Err()
+}
+
+func (s *RedisCtx) SMembers(key string) ([]string, error) {
+	return s.Rds.SMembers(s.Ctx, key).Result()
+}
+
+// Eval runs a lua script atomically on the server
+func (s *RedisCtx) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return s.Rds.Eval(s.Ctx, script, keys, args...).Result()
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Attachment is a file stored under ObjectKey and sent with a message.
+// Url is a signed download url, filled when the attachment is returned to a client.
+type Attachment struct {
+	ObjectKey   string `json:"objectKey"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	Url         string `json:"url,omitempty"`
+}
+
+// StoredAttachment is an ATTACHMENT row. MessageId is 0 until a message references it.
+type StoredAttachment struct {
+	Attachment
+	RoomId    string
+	AccountId int64
+	Checksum  string
+	MessageId int64
+	CreatedAt time.Time
+}
+
+// NewAttachmentForm announces a file the client wants to upload.
+// Checksum is the hex encoded md5 of the file.
+type NewAttachmentForm struct {
+	FileName string `json:"fileName" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+	Checksum string `json:"checksum" binding:"required"`
+}
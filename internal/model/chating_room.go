@@ -1,7 +1,45 @@
 package model
 
+import "time"
+
 type ChatingRoom struct {
-	RoomId   string `json:"roomId"`
-	RoomName string `json:"roomName"`
-	IsUsed   bool   `json:"isUsed"`
+	RoomId      string `json:"roomId"`
+	RoomName    string `json:"roomName"`
+	Description string `json:"description"`
+	OwnerId     int64  `json:"ownerId"`
+	IsUsed      bool   `json:"isUsed"`
+	IsPrivate   bool   `json:"isPrivate"`
+	IsDirect    bool   `json:"isDirect"`
+	UnreadCount int64  `json:"unreadCount"`
+}
+
+type NewChatingRoomForm struct {
+	RoomName    string `json:"roomName" binding:"required"`
+	Description string `json:"description"`
+	IsPrivate   bool   `json:"isPrivate"`
+}
+
+// UpdateChatingRoomForm only changes the fields that are present.
+// IsUsed false archives the room, true restores it.
+type UpdateChatingRoomForm struct {
+	RoomName    *string `json:"roomName"`
+	Description *string `json:"description"`
+	IsUsed      *bool   `json:"isUsed"`
+}
+
+type RoomMember struct {
+	RoomId            string    `json:"roomId"`
+	AccountId         int64     `json:"accountId"`
+	UserId            string    `json:"userId"`
+	Role              int       `json:"role"`
+	LastReadMessageId int64     `json:"lastReadMessageId"`
+	JoinedAt          time.Time `json:"joinedAt"`
+}
+
+type RoomMemberForm struct {
+	AccountId int64 `json:"accountId" binding:"required"`
+}
+
+type RoomMemberRoleForm struct {
+	Role int `json:"role" binding:"required"`
 }
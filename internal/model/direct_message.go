@@ -0,0 +1,13 @@
+package model
+
+type DirectMessageForm struct {
+	AccountId int64 `json:"accountId" binding:"required"`
+}
+
+// DirectConversation is a direct message room seen by one of its two members
+type DirectConversation struct {
+	RoomId      string   `json:"roomId"`
+	Peer        Sender   `json:"peer"`
+	LastMessage *Message `json:"lastMessage"`
+	UnreadCount int64    `json:"unreadCount"`
+}
@@ -0,0 +1,124 @@
+package model
+
+import "encoding/json"
+
+// EnvelopeVersion is the version of the websocket protocol spoken by the server
+const EnvelopeVersion = 1
+
+// envelope types
+const (
+	EnvelopeTypeMessage   = "message"
+	EnvelopeTypePing      = "ping"
+	EnvelopeTypePong      = "pong"
+	EnvelopeTypeError     = "error"
+	EnvelopeTypePresence  = "presence"
+	EnvelopeTypeTyping    = "typing"
+	EnvelopeTypeRead      = "read"
+	EnvelopeTypeResume    = "resume"
+	EnvelopeTypeAck       = "ack"
+	EnvelopeTypeDelivered = "delivered"
+
+	EnvelopeTypeMessageUpdated  = "message.updated"
+	EnvelopeTypeMessageDeleted  = "message.deleted"
+	EnvelopeTypeReactionAdded   = "reaction.added"
+	EnvelopeTypeReactionRemoved = "reaction.removed"
+	EnvelopeTypeThreadReply     = "thread.reply"
+	EnvelopeTypeMention         = "mention"
+)
+
+type Sender struct {
+	AccountId int64  `json:"accountId"`
+	UserId    string `json:"userId"`
+}
+
+// Envelope is every frame exchanged over the websocket.
+// Sender, RoomId and Ts are always stamped by the server; values sent by clients are ignored.
+// Id is the message id on frames from the server. A client may set it on its own frames as a
+// correlation id, which is echoed back on the error frame answering that frame.
+// Seq is the sequence number of a message frame within its room.
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	Id      string          `json:"id,omitempty"`
+	RoomId  string          `json:"roomId,omitempty"`
+	Sender  *Sender         `json:"sender,omitempty"`
+	Seq     int64           `json:"seq,omitempty"`
+	Ts      int64           `json:"ts"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ResumePayload asks for the messages after LastSeq, the last sequence number up to which the
+// client saw every message. Messages may arrive out of order, so it is not the highest one seen.
+// The server replays them and answers with a resume frame holding the last replayed sequence.
+// Truncated tells the client that more messages were missed than a resume replays;
+// it should reload the history instead.
+type ResumePayload struct {
+	LastSeq   int64 `json:"lastSeq"`
+	Truncated bool  `json:"truncated,omitempty"`
+}
+
+// AckPayload acknowledges message frames received by the client.
+// Message frames left unacknowledged when the connection closes are sent again on the
+// next connection of the account to the room.
+type AckPayload struct {
+	MessageIds []int64 `json:"messageIds"`
+}
+
+// DeliveredPayload answers a message frame of the client once the message is stored.
+// The delivered frame carries the id the client set on its message frame.
+type DeliveredPayload struct {
+	MessageId int64 `json:"messageId"`
+	Seq       int64 `json:"seq"`
+}
+
+// MessagePayload is a chat message. ParentId makes it a reply in the thread of that message.
+// AttachmentKeys reference uploaded attachments, the server sends them back as Attachments.
+// Mentions and MentionAll are resolved by the server from the text.
+type MessagePayload struct {
+	Text           string       `json:"text"`
+	ParentId       int64        `json:"parentId,omitempty"`
+	AttachmentKeys []string     `json:"attachmentKeys,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	Mentions       []Mention    `json:"mentions,omitempty"`
+	MentionAll     string       `json:"mentionAll,omitempty"`
+}
+
+// MentionPayload is sent to the mentioned accounts on their connections outside the room
+type MentionPayload struct {
+	Text       string `json:"text"`
+	MentionAll string `json:"mentionAll,omitempty"`
+}
+
+// ThreadReplyPayload is sent to the participants of a thread on every new reply,
+// whichever room they are looking at
+type ThreadReplyPayload struct {
+	ParentId int64  `json:"parentId"`
+	Text     string `json:"text"`
+}
+
+// PresencePayload is sent by clients with their own status only,
+// and by the server with the account of the status
+type PresencePayload struct {
+	AccountId int64  `json:"accountId,omitempty"`
+	UserId    string `json:"userId,omitempty"`
+	Status    string `json:"status"`
+}
+
+// TypingPayload is relayed to the other members of the room and never stored.
+// Clients should hide the indicator once ExpiresInMs passed without a renewal.
+type TypingPayload struct {
+	Typing      bool  `json:"typing"`
+	ExpiresInMs int64 `json:"expiresInMs,omitempty"`
+}
+
+// ReadPayload marks every message of the room up to MessageId as read by the sender
+type ReadPayload struct {
+	MessageId int64 `json:"messageId"`
+}
+
+// ErrorPayload answers a rejected client frame. RetryAfterMs is set on rate_limited errors.
+type ErrorPayload struct {
+	Code         int    `json:"code"`
+	Message      string `json:"message"`
+	RetryAfterMs int64  `json:"retryAfterMs,omitempty"`
+}
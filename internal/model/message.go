@@ -0,0 +1,89 @@
+package model
+
+import "time"
+
+// Message is a stored chat message. A deleted message stays in history as a tombstone
+// with an empty body and Deleted set.
+// A reply has the id of its thread's first message as ParentId; only that message keeps
+// the reply count and last reply time of the thread.
+type Message struct {
+	Id          int64        `json:"id"`
+	Seq         int64        `json:"seq"`
+	RoomId      string       `json:"roomId"`
+	AccountId   int64        `json:"accountId"`
+	Body        string       `json:"body"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	EditedAt    *time.Time   `json:"editedAt,omitempty"`
+	Deleted     bool         `json:"deleted"`
+	ParentId    *int64       `json:"parentId,omitempty"`
+	ReplyCount  int          `json:"replyCount"`
+	LastReplyAt *time.Time   `json:"lastReplyAt,omitempty"`
+	Mentions    []Mention    `json:"mentions"`
+	MentionAll  string       `json:"mentionAll,omitempty"`
+	Attachments []Attachment `json:"attachments"`
+	Reactions   []Reaction   `json:"reactions"`
+}
+
+// NewMessageForm is a message to save. ParentId makes it a reply, AttachmentKeys are the
+// object keys of uploaded attachments.
+type NewMessageForm struct {
+	Body           string
+	ParentId       int64
+	AttachmentKeys []string
+}
+
+// Mention is a room member named in a message body as @userId.
+// Clients highlight the @userId tokens of the body that have a mention.
+type Mention struct {
+	AccountId int64  `json:"accountId"`
+	UserId    string `json:"userId"`
+}
+
+// Reaction is the aggregate of one emoji on a message
+type Reaction struct {
+	Emoji      string  `json:"emoji"`
+	Count      int     `json:"count"`
+	AccountIds []int64 `json:"accountIds"`
+}
+
+type ReactionForm struct {
+	Emoji string `json:"emoji" binding:"required"`
+}
+
+// ReactionPayload is the incremental change of a reaction; Count is the total after the change
+type ReactionPayload struct {
+	Emoji     string `json:"emoji"`
+	AccountId int64  `json:"accountId"`
+	Count     int    `json:"count"`
+}
+
+// MessagePage is one page of room history, newest first.
+// NextCursor is empty when there is no older message.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"nextCursor"`
+}
+
+type UpdateMessageForm struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// MessageEdit is a previous body of an edited or deleted message
+type MessageEdit struct {
+	MessageId int64     `json:"messageId"`
+	Body      string    `json:"body"`
+	EditedBy  int64     `json:"editedBy"`
+	EditedAt  time.Time `json:"editedAt"`
+}
+
+type MessageUpdatedPayload struct {
+	Text       string    `json:"text"`
+	EditedBy   int64     `json:"editedBy"`
+	EditedAt   time.Time `json:"editedAt"`
+	Mentions   []Mention `json:"mentions"`
+	MentionAll string    `json:"mentionAll,omitempty"`
+}
+
+type MessageDeletedPayload struct {
+	DeletedBy int64 `json:"deletedBy"`
+}
@@ -0,0 +1,6 @@
+package model
+
+type Presence struct {
+	AccountId int64  `json:"accountId"`
+	Status    string `json:"status"`
+}
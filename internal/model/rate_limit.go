@@ -0,0 +1,9 @@
+package model
+
+import "time"
+
+// RateLimit is a token bucket holding up to Burst tokens, refilled with one token every Refill
+type RateLimit struct {
+	Burst  int
+	Refill time.Duration
+}
@@ -0,0 +1,43 @@
+package model
+
+import "time"
+
+// SearchQuery selects the messages to search. Terms are the lowercased words of the
+// query text, all of which must match. Zero From, Before and BeforeId leave the range open.
+type SearchQuery struct {
+	Terms    []string
+	RoomIds  []string
+	From     time.Time
+	Before   time.Time
+	BeforeId int64
+	Limit    int
+}
+
+// SearchHit is a message matching a search. Snippet is the html escaped part of the body
+// around the first match, with every match wrapped in <mark>.
+type SearchHit struct {
+	MessageId int64     `json:"messageId"`
+	RoomId    string    `json:"roomId"`
+	AccountId int64     `json:"accountId"`
+	Snippet   string    `json:"snippet"`
+	CreatedAt time.Time `json:"createdAt"`
+	Body      string    `json:"-"`
+}
+
+// SearchPage is one page of search results, newest first.
+// NextCursor is empty when there is no older result.
+type SearchPage struct {
+	Results    []SearchHit `json:"results"`
+	NextCursor string      `json:"nextCursor"`
+}
+
+// SearchForm is a search request. RoomId narrows it to one room, From and Before to a
+// time range, both optional.
+type SearchForm struct {
+	Text   string
+	RoomId string
+	From   time.Time
+	Before time.Time
+	Cursor string
+	Limit  int
+}
@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeNotifier keeps the notifications in memory instead of sending them, for tests
+type FakeNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func NewFakeNotifier() *FakeNotifier {
+	return &FakeNotifier{}
+}
+
+func (n *FakeNotifier) Notify(ctx context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+// Notifications returns the notifications received so far
+func (n *FakeNotifier) Notifications() []Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return append([]Notification(nil), n.notifications...)
+}
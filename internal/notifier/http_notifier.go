@@ -0,0 +1,69 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HttpNotifier posts notifications to an FCM/APNs style push gateway
+type HttpNotifier struct {
+	endpoint string
+	apiKey   string
+	client   *http.Client
+}
+
+func NewHttpNotifier(endpoint string, apiKey string, timeout time.Duration) *HttpNotifier {
+	return &HttpNotifier{
+		endpoint: endpoint,
+		apiKey:   apiKey,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type pushMessage struct {
+	To           string            `json:"to"`
+	Notification pushContent       `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type pushContent struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+func (n *HttpNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(pushMessage{
+		To:           notification.PushToken,
+		Notification: pushContent{Title: notification.Title, Body: notification.Body},
+		Data:         notification.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if n.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+n.apiKey)
+	}
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("push gateway returned %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHttpNotifierPostsNotification(t *testing.T) {
+	var received pushMessage
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	notifier := NewHttpNotifier(server.URL, "secret", time.Second)
+	err := notifier.Notify(context.Background(), Notification{
+		AccountId: 1,
+		PushToken: "device-token",
+		Title:     "alice",
+		Body:      "hello",
+		Data:      map[string]string{"roomId": "room-1"},
+	})
+	if err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	if authorization != "Bearer secret" {
+		t.Fatalf("unexpected authorization %q", authorization)
+	}
+	if received.To != "device-token" || received.Notification.Body != "hello" || received.Data["roomId"] != "room-1" {
+		t.Fatalf("unexpected push message %+v", received)
+	}
+}
+
+func TestHttpNotifierFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	notifier := NewHttpNotifier(server.URL, "", time.Second)
+	if err := notifier.Notify(context.Background(), Notification{PushToken: "device-token"}); err == nil {
+		t.Fatal("expected an error for a failed push")
+	}
+}
@@ -0,0 +1,43 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"chating_service/internal/config"
+)
+
+// Notification is a push notification to the device of one account
+type Notification struct {
+	AccountId int64
+	PushToken string
+	Title     string
+	Body      string
+	Data      map[string]string
+}
+
+// Notifier delivers push notifications to members without a live connection
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// NewNotifier returns the notifier of the push config.
+// Notifications are dropped if no push endpoint is configured.
+func NewNotifier(pushConfig config.PushConfig) Notifier {
+	if pushConfig.Endpoint == "" {
+		return NopNotifier{}
+	}
+
+	timeout := time.Duration(pushConfig.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return NewHttpNotifier(pushConfig.Endpoint, pushConfig.ApiKey, timeout)
+}
+
+// NopNotifier drops every notification
+type NopNotifier struct{}
+
+func (NopNotifier) Notify(ctx context.Context, notification Notification) error {
+	return nil
+}
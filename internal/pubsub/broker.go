@@ -0,0 +1,62 @@
+package pubsub
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+const (
+	roomTopicPrefix = "chating_room_"
+	userTopicPrefix = "chating_user_"
+)
+
+// ControlTopic carries instructions every hub must apply, such as disconnecting a kicked member
+const ControlTopic = "chating_hub_control"
+
+// Message is a payload received on a subscribed topic
+type Message struct {
+	Topic string
+	Data  []byte
+}
+
+// Broker carries broadcasts between server instances.
+// Every instance publishes to a topic and only receives messages of the topics it subscribed.
+type Broker interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	Subscribe(ctx context.Context, topic string) error
+	Unsubscribe(ctx context.Context, topic string) error
+	// Messages delivers the messages of every subscribed topic
+	Messages() <-chan Message
+	Close() error
+}
+
+// RoomTopic returns the topic of a chating room
+func RoomTopic(roomId string) string {
+	return roomTopicPrefix + roomId
+}
+
+// UserTopic returns the topic reaching every connection of an account, whatever its room
+func UserTopic(accountId int64) string {
+	return userTopicPrefix + strconv.FormatInt(accountId, 10)
+}
+
+// AccountIdFromTopic returns the account id of a topic made by UserTopic
+func AccountIdFromTopic(topic string) (int64, bool) {
+	if !strings.HasPrefix(topic, userTopicPrefix) {
+		return 0, false
+	}
+	accountId, err := strconv.ParseInt(strings.TrimPrefix(topic, userTopicPrefix), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return accountId, true
+}
+
+// RoomIdFromTopic returns the room id of a topic made by RoomTopic
+func RoomIdFromTopic(topic string) (string, bool) {
+	if len(topic) <= len(roomTopicPrefix) || topic[:len(roomTopicPrefix)] != roomTopicPrefix {
+		return "", false
+	}
+	return topic[len(roomTopicPrefix):], true
+}
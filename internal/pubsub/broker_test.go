@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+func TestMemoryBrokerFanOut(t *testing.T) {
+	bus := NewMemoryBus()
+	testBrokerFanOut(t, bus.NewBroker(), bus.NewBroker())
+}
+
+// TestRedisBrokerFanOut runs against a local redis-server, e.g. REDIS_ADDR=localhost:6379
+func TestRedisBrokerFanOut(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+
+	first := NewRedisBroker(redis.NewClient(&redis.Options{Addr: addr}))
+	second := NewRedisBroker(redis.NewClient(&redis.Options{Addr: addr}))
+	testBrokerFanOut(t, first, second)
+}
+
+func testBrokerFanOut(t *testing.T, first Broker, second Broker) {
+	ctx := context.Background()
+	defer first.Close()
+	defer second.Close()
+
+	topic := RoomTopic("room-1")
+	if err := second.Subscribe(ctx, topic); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	// the redis subscription is confirmed asynchronously
+	time.Sleep(100 * time.Millisecond)
+
+	if err := first.Publish(ctx, RoomTopic("room-2"), []byte("other room")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if err := first.Publish(ctx, topic, []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case message := <-second.Messages():
+		if message.Topic != topic || string(message.Data) != "hello" {
+			t.Fatalf("unexpected message %s: %s", message.Topic, message.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	select {
+	case message := <-first.Messages():
+		t.Fatalf("broker without subscription received %s", message.Topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if roomId, ok := RoomIdFromTopic(topic); !ok || roomId != "room-1" {
+		t.Fatalf("unexpected room id %q", roomId)
+	}
+}
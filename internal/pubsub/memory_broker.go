@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBus is an in-process stand-in for redis pub/sub.
+// Brokers created from the same bus behave like server instances sharing one redis.
+type MemoryBus struct {
+	mu      sync.RWMutex
+	brokers map[*MemoryBroker]bool
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{brokers: make(map[*MemoryBroker]bool)}
+}
+
+// NewBroker returns a broker attached to the bus
+func (b *MemoryBus) NewBroker() *MemoryBroker {
+	broker := &MemoryBroker{
+		bus:      b,
+		topics:   make(map[string]bool),
+		messages: make(chan Message, messageBufferSize),
+	}
+
+	b.mu.Lock()
+	b.brokers[broker] = true
+	b.mu.Unlock()
+	return broker
+}
+
+// NewMemoryBroker returns a broker for a single instance deployment
+func NewMemoryBroker() *MemoryBroker {
+	return NewMemoryBus().NewBroker()
+}
+
+type MemoryBroker struct {
+	bus      *MemoryBus
+	mu       sync.RWMutex
+	topics   map[string]bool
+	messages chan Message
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	b.bus.mu.RLock()
+	defer b.bus.mu.RUnlock()
+
+	for broker := range b.bus.brokers {
+		broker.deliver(ctx, Message{Topic: topic, Data: data})
+	}
+	return nil
+}
+
+func (b *MemoryBroker) deliver(ctx context.Context, message Message) {
+	b.mu.RLock()
+	subscribed := b.topics[message.Topic]
+	b.mu.RUnlock()
+	if !subscribed {
+		return
+	}
+
+	select {
+	case b.messages <- message:
+	case <-ctx.Done():
+	}
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, topic string) error {
+	b.mu.Lock()
+	b.topics[topic] = true
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBroker) Unsubscribe(ctx context.Context, topic string) error {
+	b.mu.Lock()
+	delete(b.topics, topic)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBroker) Messages() <-chan Message {
+	return b.messages
+}
+
+func (b *MemoryBroker) Close() error {
+	b.bus.mu.Lock()
+	delete(b.bus.brokers, b)
+	b.bus.mu.Unlock()
+
+	close(b.messages)
+	return nil
+}
@@ -0,0 +1,56 @@
+package pubsub
+
+import (
+	"context"
+
+	redis "github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const messageBufferSize = 256
+
+// RedisBroker fans out messages to every instance through redis pub/sub
+type RedisBroker struct {
+	rds      *redis.Client
+	pubSub   *redis.PubSub
+	messages chan Message
+}
+
+func NewRedisBroker(rds *redis.Client) *RedisBroker {
+	broker := &RedisBroker{
+		rds:      rds,
+		pubSub:   rds.Subscribe(context.Background()),
+		messages: make(chan Message, messageBufferSize),
+	}
+	go broker.receive()
+	return broker
+}
+
+func (b *RedisBroker) receive() {
+	defer close(b.messages)
+
+	for message := range b.pubSub.Channel() {
+		b.messages <- Message{Topic: message.Channel, Data: []byte(message.Payload)}
+	}
+	log.Info().Msg("RedisBroker:: subscription closed")
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, topic string, data []byte) error {
+	return b.rds.Publish(ctx, topic, data).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, topic string) error {
+	return b.pubSub.Subscribe(ctx, topic)
+}
+
+func (b *RedisBroker) Unsubscribe(ctx context.Context, topic string) error {
+	return b.pubSub.Unsubscribe(ctx, topic)
+}
+
+func (b *RedisBroker) Messages() <-chan Message {
+	return b.messages
+}
+
+func (b *RedisBroker) Close() error {
+	return b.pubSub.Close()
+}
@@ -26,6 +26,7 @@ import (
 	"chating_service/internal/db"
 	"chating_service/internal/model"
 
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -135,7 +136,7 @@ func GetUserAccountByAccountId(dbCtx *db.DbCtx, accountId int64) (model.Account,
 		SELECT id, 
 		       user_id, 
 		       password, 
-		       is_used, 
+		       is_used
         FROM ACCOUNT 
         WHERE id=?
 	`
@@ -190,3 +191,78 @@ func GetUserAccount(dbCtx *db.DbCtx, userId string) (model.Account, error) {
 	}
 	return account, nil
 }
+
+// FetchPushTokens returns the push token of each account that registered one
+func FetchPushTokens(dbCtx *db.DbCtx, accountIds []int64) (map[int64]string, error) {
+	pushTokens := make(map[int64]string)
+	if len(accountIds) == 0 {
+		return pushTokens, nil
+	}
+
+	args := make([]interface{}, 0, len(accountIds))
+	for _, accountId := range accountIds {
+		args = append(args, accountId)
+	}
+
+	selectSQL := `
+		SELECT account_id,
+		       push_token
+		FROM PUSH_ENDPOINT
+		WHERE account_id IN (?` + strings.Repeat(",?", len(accountIds)-1) + `)
+		  AND push_token <> ''
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch push tokens: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountId int64
+		var pushToken string
+		if err := rows.Scan(&accountId, &pushToken); err != nil {
+			return nil, err
+		}
+		pushTokens[accountId] = pushToken
+	}
+
+	return pushTokens, rows.Err()
+}
+
+// FetchUserIds returns the user id of each account
+func FetchUserIds(dbCtx *db.DbCtx, accountIds []int64) (map[int64]string, error) {
+	userIds := make(map[int64]string)
+	if len(accountIds) == 0 {
+		return userIds, nil
+	}
+
+	args := make([]interface{}, 0, len(accountIds))
+	for _, accountId := range accountIds {
+		args = append(args, accountId)
+	}
+
+	selectSQL := `
+		SELECT id,
+		       user_id
+		FROM ACCOUNT
+		WHERE id IN (?` + strings.Repeat(",?", len(accountIds)-1) + `)
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch user ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var accountId int64
+		var userId string
+		if err := rows.Scan(&accountId, &userId); err != nil {
+			return nil, err
+		}
+		userIds[accountId] = userId
+	}
+
+	return userIds, rows.Err()
+}
@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"database/sql"
+	"strings"
+
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InsertAttachment records an announced upload that no message references yet
+func InsertAttachment(dbCtx *db.DbCtx, attachment *model.StoredAttachment) error {
+	insertSQL := `
+		INSERT INTO ATTACHMENT
+			(
+				object_key,
+				room_id,
+				account_id,
+				file_name,
+				content_type,
+				size,
+				checksum,
+				created_at
+			)
+		VALUES
+			(?,?,?,?,?,?,?,?)
+	`
+	_, err := dbCtx.ExecContext(insertSQL,
+		attachment.ObjectKey,
+		attachment.RoomId,
+		attachment.AccountId,
+		attachment.FileName,
+		attachment.ContentType,
+		attachment.Size,
+		attachment.Checksum,
+		attachment.CreatedAt,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert attachment: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func GetAttachment(dbCtx *db.DbCtx, objectKey string) (model.StoredAttachment, error) {
+	selectSQL := `
+		SELECT
+			object_key,
+			room_id,
+			account_id,
+			file_name,
+			content_type,
+			size,
+			checksum,
+			IFNULL(message_id, 0),
+			created_at
+		FROM ATTACHMENT
+		WHERE object_key = ?
+	`
+	var attachment model.StoredAttachment
+	err := dbCtx.DB.QueryRowContext(dbCtx.Ctx, selectSQL, objectKey).Scan(
+		&attachment.ObjectKey,
+		&attachment.RoomId,
+		&attachment.AccountId,
+		&attachment.FileName,
+		&attachment.ContentType,
+		&attachment.Size,
+		&attachment.Checksum,
+		&attachment.MessageId,
+		&attachment.CreatedAt,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error().Msgf("Failed to get attachment: %v", err)
+	}
+	return attachment, err
+}
+
+// AttachToMessage links an attachment to the message that sends it.
+// It returns false if another message already references the attachment.
+func AttachToMessage(dbCtx *db.DbCtx, objectKey string, messageId int64) (bool, error) {
+	updateSQL := `
+		UPDATE ATTACHMENT
+			SET message_id = ?
+		WHERE object_key = ?
+		  AND message_id IS NULL
+	`
+	result, err := dbCtx.ExecContext(updateSQL, messageId, objectKey)
+	if err != nil {
+		log.Error().Msgf("Failed to attach to message: %v", err)
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// FetchRoomObjectKeys returns the object keys of the attachments uploaded to the room,
+// sent or not
+func FetchRoomObjectKeys(dbCtx *db.DbCtx, roomId string) ([]string, error) {
+	selectSQL := `
+		SELECT object_key
+		FROM ATTACHMENT
+		WHERE room_id = ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, roomId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch room object keys: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	objectKeys := []string{}
+	for rows.Next() {
+		var objectKey string
+		if err := rows.Scan(&objectKey); err != nil {
+			return nil, err
+		}
+		objectKeys = append(objectKeys, objectKey)
+	}
+
+	return objectKeys, rows.Err()
+}
+
+// FetchAttachments returns the attachments of the messages by message id, in upload order
+func FetchAttachments(dbCtx *db.DbCtx, messageIds []int64) (map[int64][]model.Attachment, error) {
+	attachments := make(map[int64][]model.Attachment)
+	if len(messageIds) == 0 {
+		return attachments, nil
+	}
+
+	args := make([]interface{}, 0, len(messageIds))
+	for _, messageId := range messageIds {
+		args = append(args, messageId)
+	}
+
+	selectSQL := `
+		SELECT
+			message_id,
+			object_key,
+			file_name,
+			content_type,
+			size
+		FROM ATTACHMENT
+		WHERE message_id IN (?` + strings.Repeat(",?", len(messageIds)-1) + `)
+		ORDER BY message_id, id
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch attachments: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId int64
+		var attachment model.Attachment
+		err := rows.Scan(&messageId, &attachment.ObjectKey, &attachment.FileName, &attachment.ContentType, &attachment.Size)
+		if err != nil {
+			return nil, err
+		}
+		attachments[messageId] = append(attachments[messageId], attachment)
+	}
+
+	return attachments, rows.Err()
+}
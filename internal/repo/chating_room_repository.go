@@ -1,20 +1,44 @@
 package repo
 
 import (
+	"chating_service/internal/constants"
 	"chating_service/internal/db"
 	"chating_service/internal/model"
 
 	"github.com/rs/zerolog/log"
 )
 
-func FetchChatingRoom(dbCtx *db.DbCtx) ([]model.ChatingRoom, error) {
+// FetchChatingRoom returns the active rooms the account can see:
+// every public room and the private rooms it is a member of.
+// The unread count is the number of messages of others after the read marker of the account,
+// deleted messages and thread replies aside.
+func FetchChatingRoom(dbCtx *db.DbCtx, accountId int64) ([]model.ChatingRoom, error) {
 	selectSQL := `
 		SELECT 
-			id,
-			name
-		FROM CHATING_ROOM
+			r.id,
+			r.name,
+			r.description,
+			r.owner_id,
+			r.is_used,
+			r.is_private,
+			CASE WHEN m.account_id IS NULL THEN 0
+			     ELSE (
+			         SELECT COUNT(*)
+			         FROM MESSAGE msg
+			         WHERE msg.room_id = r.id
+			           AND msg.id > m.last_read_message_id
+			           AND msg.account_id <> m.account_id
+			           AND msg.deleted_at IS NULL
+			           AND msg.parent_id IS NULL
+			     )
+			END AS unread_count
+		FROM CHATING_ROOM r
+		LEFT JOIN ROOM_MEMBER m ON m.room_id = r.id AND m.account_id = ?
+		WHERE r.is_used = 1
+		  AND r.is_direct = 0
+		  AND (r.is_private = 0 OR m.account_id IS NOT NULL)
 	`
-	rows, err := dbCtx.DB.Query(selectSQL)
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, accountId)
 	if err != nil {
 		log.Error().Msgf("Failed to fetch chating room: %v", err)
 		return nil, err
@@ -27,6 +51,11 @@ func FetchChatingRoom(dbCtx *db.DbCtx) ([]model.ChatingRoom, error) {
 		err := rows.Scan(
 			&chatingRoom.RoomId,
 			&chatingRoom.RoomName,
+			&chatingRoom.Description,
+			&chatingRoom.OwnerId,
+			&chatingRoom.IsUsed,
+			&chatingRoom.IsPrivate,
+			&chatingRoom.UnreadCount,
 		)
 		if err != nil {
 			log.Error().Msgf("Failed to scan chating room: %v", err)
@@ -37,3 +66,115 @@ func FetchChatingRoom(dbCtx *db.DbCtx) ([]model.ChatingRoom, error) {
 
 	return chatingRooms, nil
 }
+
+func GetChatingRoom(dbCtx *db.DbCtx, roomId string) (model.ChatingRoom, error) {
+	chatingRoom := model.ChatingRoom{}
+	selectSQL := `
+		SELECT
+			id,
+			name,
+			description,
+			owner_id,
+			is_used,
+			is_private,
+			is_direct
+		FROM CHATING_ROOM
+		WHERE id = ?
+	`
+	err := dbCtx.DB.QueryRowContext(dbCtx.Ctx, selectSQL, roomId).Scan(
+		&chatingRoom.RoomId,
+		&chatingRoom.RoomName,
+		&chatingRoom.Description,
+		&chatingRoom.OwnerId,
+		&chatingRoom.IsUsed,
+		&chatingRoom.IsPrivate,
+		&chatingRoom.IsDirect,
+	)
+	if err != nil {
+		return chatingRoom, err
+	}
+
+	return chatingRoom, nil
+}
+
+func InsertChatingRoom(dbCtx *db.DbCtx, chatingRoom *model.ChatingRoom) error {
+	insertSQL := `
+		INSERT INTO CHATING_ROOM
+			(
+				id,
+				name,
+				description,
+				owner_id,
+				is_used,
+				is_private,
+				created_at,
+				created_by
+			)
+		VALUES
+			(?,?,?,?,?,?,current_timestamp(3),?)
+	`
+	_, err := dbCtx.ExecContext(insertSQL,
+		chatingRoom.RoomId,
+		chatingRoom.RoomName,
+		chatingRoom.Description,
+		chatingRoom.OwnerId,
+		chatingRoom.IsUsed,
+		chatingRoom.IsPrivate,
+		constants.ServerName,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert chating room: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func UpdateChatingRoom(dbCtx *db.DbCtx, chatingRoom *model.ChatingRoom) error {
+	updateSQL := `
+		UPDATE CHATING_ROOM
+			SET name = ?,
+			    description = ?,
+			    is_used = ?,
+			    updated_at = current_timestamp(3),
+			    updated_by = ?
+		WHERE id = ?
+	`
+	_, err := dbCtx.ExecContext(updateSQL,
+		chatingRoom.RoomName,
+		chatingRoom.Description,
+		chatingRoom.IsUsed,
+		constants.ServerName,
+		chatingRoom.RoomId,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to update chating room: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteChatingRoom removes the room with its members, its messages and what belongs to them:
+// edit history, reactions, mentions and attachment rows. The attachment objects are left to the
+// caller, see FetchRoomObjectKeys. It should run in a transaction, see DbCtx.RunInTxn.
+func DeleteChatingRoom(dbCtx *db.DbCtx, roomId string) error {
+	deleteSQLs := []string{
+		`DELETE h FROM MESSAGE_EDIT_HISTORY h JOIN MESSAGE m ON m.id = h.message_id WHERE m.room_id = ?`,
+		`DELETE r FROM MESSAGE_REACTION r JOIN MESSAGE m ON m.id = r.message_id WHERE m.room_id = ?`,
+		`DELETE n FROM MESSAGE_MENTION n JOIN MESSAGE m ON m.id = n.message_id WHERE m.room_id = ?`,
+		`DELETE FROM ATTACHMENT WHERE room_id = ?`,
+		`DELETE FROM MESSAGE WHERE room_id = ?`,
+		`DELETE FROM ROOM_MEMBER WHERE room_id = ?`,
+		`DELETE FROM CHATING_ROOM WHERE id = ?`,
+	}
+	for _, deleteSQL := range deleteSQLs {
+		_, err := dbCtx.ExecContext(deleteSQL, roomId)
+		if err != nil {
+			log.Error().Msgf("Failed to delete chating room: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
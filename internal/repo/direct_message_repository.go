@@ -0,0 +1,121 @@
+package repo
+
+import (
+	"database/sql"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InsertDirectRoom creates the direct message room unless it already exists
+func InsertDirectRoom(dbCtx *db.DbCtx, chatingRoom *model.ChatingRoom) error {
+	insertSQL := `
+		INSERT IGNORE INTO CHATING_ROOM
+			(
+				id,
+				name,
+				description,
+				owner_id,
+				is_used,
+				is_private,
+				is_direct,
+				created_at,
+				created_by
+			)
+		VALUES
+			(?,?,'',0,1,1,1,current_timestamp(3),?)
+	`
+	_, err := dbCtx.ExecContext(insertSQL,
+		chatingRoom.RoomId,
+		chatingRoom.RoomName,
+		constants.ServerName,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert direct room: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// FetchDirectConversations returns the direct message rooms of the account,
+// the most recently active first. Deleted messages and thread replies are neither the last
+// message nor unread.
+func FetchDirectConversations(dbCtx *db.DbCtx, accountId int64) ([]model.DirectConversation, error) {
+	selectSQL := `
+		SELECT
+			r.id,
+			peer.account_id,
+			a.user_id,
+			msg.id,
+			msg.account_id,
+			msg.body,
+			msg.created_at,
+			(
+				SELECT COUNT(*)
+				FROM MESSAGE u
+				WHERE u.room_id = r.id
+				  AND u.id > me.last_read_message_id
+				  AND u.account_id <> me.account_id
+				  AND u.deleted_at IS NULL
+				  AND u.parent_id IS NULL
+			) AS unread_count
+		FROM ROOM_MEMBER me
+		JOIN CHATING_ROOM r ON r.id = me.room_id AND r.is_direct = 1 AND r.is_used = 1
+		JOIN ROOM_MEMBER peer ON peer.room_id = r.id AND peer.account_id <> me.account_id
+		JOIN ACCOUNT a ON a.id = peer.account_id
+		LEFT JOIN MESSAGE msg ON msg.id = (
+			SELECT MAX(last.id)
+			FROM MESSAGE last
+			WHERE last.room_id = r.id
+			  AND last.deleted_at IS NULL
+			  AND last.parent_id IS NULL
+		)
+		WHERE me.account_id = ?
+		ORDER BY COALESCE(msg.id, 0) DESC
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, accountId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch direct conversations: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []model.DirectConversation{}
+	for rows.Next() {
+		var conversation model.DirectConversation
+		var messageId, messageAccountId sql.NullInt64
+		var messageBody sql.NullString
+		var messageCreatedAt sql.NullTime
+		err := rows.Scan(
+			&conversation.RoomId,
+			&conversation.Peer.AccountId,
+			&conversation.Peer.UserId,
+			&messageId,
+			&messageAccountId,
+			&messageBody,
+			&messageCreatedAt,
+			&conversation.UnreadCount,
+		)
+		if err != nil {
+			log.Error().Msgf("Failed to scan direct conversation: %v", err)
+			return nil, err
+		}
+
+		if messageId.Valid {
+			conversation.LastMessage = &model.Message{
+				Id:        messageId.Int64,
+				RoomId:    conversation.RoomId,
+				AccountId: messageAccountId.Int64,
+				Body:      messageBody.String,
+				CreatedAt: messageCreatedAt.Time,
+			}
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, rows.Err()
+}
@@ -0,0 +1,160 @@
+package repo
+
+import (
+	"strings"
+
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FetchMentionableMembers returns the members of the room whose user id is one of userIds
+func FetchMentionableMembers(dbCtx *db.DbCtx, roomId string, userIds []string) ([]model.Mention, error) {
+	mentions := []model.Mention{}
+	if len(userIds) == 0 {
+		return mentions, nil
+	}
+
+	args := make([]interface{}, 0, len(userIds)+1)
+	args = append(args, roomId)
+	for _, userId := range userIds {
+		args = append(args, userId)
+	}
+
+	selectSQL := `
+		SELECT
+			a.id,
+			a.user_id
+		FROM ROOM_MEMBER m
+		JOIN ACCOUNT a ON a.id = m.account_id
+		WHERE m.room_id = ?
+		  AND a.is_used = 1
+		  AND a.user_id IN (?` + strings.Repeat(",?", len(userIds)-1) + `)
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch mentionable members: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mention model.Mention
+		if err := rows.Scan(&mention.AccountId, &mention.UserId); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// InsertMentions stores the accounts mentioned by a message
+func InsertMentions(dbCtx *db.DbCtx, messageId int64, mentions []model.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(mentions)*2)
+	for _, mention := range mentions {
+		args = append(args, messageId, mention.AccountId)
+	}
+
+	insertSQL := `
+		INSERT IGNORE INTO MESSAGE_MENTION
+			(
+				message_id,
+				account_id
+			)
+		VALUES
+			(?,?)` + strings.Repeat(",(?,?)", len(mentions)-1)
+	_, err := dbCtx.ExecContext(insertSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to insert mentions: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMentions removes the mentions of a message, e.g. before its edited body is resolved again
+func DeleteMentions(dbCtx *db.DbCtx, messageId int64) error {
+	deleteSQL := `
+		DELETE FROM MESSAGE_MENTION
+		WHERE message_id = ?
+	`
+	_, err := dbCtx.ExecContext(deleteSQL, messageId)
+	if err != nil {
+		log.Error().Msgf("Failed to delete mentions: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// FetchMentions returns the mentions of the messages by message id
+func FetchMentions(dbCtx *db.DbCtx, messageIds []int64) (map[int64][]model.Mention, error) {
+	mentions := make(map[int64][]model.Mention)
+	if len(messageIds) == 0 {
+		return mentions, nil
+	}
+
+	args := make([]interface{}, 0, len(messageIds))
+	for _, messageId := range messageIds {
+		args = append(args, messageId)
+	}
+
+	selectSQL := `
+		SELECT
+			mm.message_id,
+			a.id,
+			a.user_id
+		FROM MESSAGE_MENTION mm
+		JOIN ACCOUNT a ON a.id = mm.account_id
+		WHERE mm.message_id IN (?` + strings.Repeat(",?", len(messageIds)-1) + `)
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch mentions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId int64
+		var mention model.Mention
+		if err := rows.Scan(&messageId, &mention.AccountId, &mention.UserId); err != nil {
+			return nil, err
+		}
+		mentions[messageId] = append(mentions[messageId], mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+// FetchRoomMemberIds returns the account ids of the members of the room
+func FetchRoomMemberIds(dbCtx *db.DbCtx, roomId string) ([]int64, error) {
+	selectSQL := `
+		SELECT account_id
+		FROM ROOM_MEMBER
+		WHERE room_id = ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, roomId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch room member ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	accountIds := []int64{}
+	for rows.Next() {
+		var accountId int64
+		if err := rows.Scan(&accountId); err != nil {
+			return nil, err
+		}
+		accountIds = append(accountIds, accountId)
+	}
+
+	return accountIds, rows.Err()
+}
@@ -0,0 +1,395 @@
+package repo
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// NextRoomSeq takes the next sequence number of the room.
+// The room row stays locked until the transaction ends, so sequence numbers of a room are
+// committed in order.
+func NextRoomSeq(dbCtx *db.DbCtx, roomId string) (int64, error) {
+	updateSQL := `
+		UPDATE CHATING_ROOM
+			SET last_seq = LAST_INSERT_ID(last_seq + 1)
+		WHERE id = ?
+	`
+	result, err := dbCtx.ExecContext(updateSQL, roomId)
+	if err != nil {
+		log.Error().Msgf("Failed to take room sequence: %v", err)
+		return 0, err
+	}
+
+	// LAST_INSERT_ID(expr) makes the driver report the new value as the insert id
+	return result.LastInsertId()
+}
+
+// InsertMessage stores a chat message and sets the generated id on it
+func InsertMessage(dbCtx *db.DbCtx, message *model.Message) error {
+	insertSQL := `
+		INSERT INTO MESSAGE
+			(
+				room_id,
+				seq,
+				account_id,
+				body,
+				parent_id,
+				mention_all,
+				created_at,
+				created_by
+			)
+		VALUES
+			(?,?,?,?,?,NULLIF(?, ''),?,?)
+	`
+	result, err := dbCtx.ExecContext(insertSQL,
+		message.RoomId,
+		message.Seq,
+		message.AccountId,
+		message.Body,
+		message.ParentId,
+		message.MentionAll,
+		message.CreatedAt,
+		constants.ServerName,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert message: %v", err)
+		return err
+	}
+
+	message.Id, err = result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const messageColumns = `
+			id,
+			room_id,
+			account_id,
+			body,
+			created_at,
+			updated_at,
+			deleted_at,
+			parent_id,
+			reply_count,
+			last_reply_at,
+			IFNULL(mention_all, ''),
+			IFNULL(seq, 0)`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row rowScanner) (model.Message, error) {
+	var message model.Message
+	var updatedAt, deletedAt, lastReplyAt sql.NullTime
+	var parentId sql.NullInt64
+	err := row.Scan(
+		&message.Id,
+		&message.RoomId,
+		&message.AccountId,
+		&message.Body,
+		&message.CreatedAt,
+		&updatedAt,
+		&deletedAt,
+		&parentId,
+		&message.ReplyCount,
+		&lastReplyAt,
+		&message.MentionAll,
+		&message.Seq,
+	)
+	if err != nil {
+		return message, err
+	}
+
+	if parentId.Valid {
+		message.ParentId = &parentId.Int64
+	}
+	if lastReplyAt.Valid {
+		message.LastReplyAt = &lastReplyAt.Time
+	}
+
+	if updatedAt.Valid {
+		message.EditedAt = &updatedAt.Time
+	}
+	if deletedAt.Valid {
+		message.Deleted = true
+		message.Body = ""
+	}
+	return message, nil
+}
+
+// FetchMessages returns up to limit messages of the room older than beforeId, newest first.
+// A parentId of 0 returns the messages of the room itself, otherwise the replies to that message.
+// A beforeId of 0 starts from the latest message.
+func FetchMessages(dbCtx *db.DbCtx, roomId string, parentId int64, beforeId int64, limit int) ([]model.Message, error) {
+	threadCondition := "AND parent_id IS NULL"
+	args := []interface{}{roomId}
+	if parentId > 0 {
+		threadCondition = "AND parent_id = ?"
+		args = append(args, parentId)
+	}
+	args = append(args, beforeId, beforeId, limit)
+
+	selectSQL := `
+		SELECT` + messageColumns + `
+		FROM MESSAGE
+		WHERE room_id = ?
+		  ` + threadCondition + `
+		  AND (? = 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch messages: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []model.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			log.Error().Msgf("Failed to scan message: %v", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+func GetMessage(dbCtx *db.DbCtx, messageId int64) (model.Message, error) {
+	selectSQL := `
+		SELECT` + messageColumns + `
+		FROM MESSAGE
+		WHERE id = ?
+	`
+	return scanMessage(dbCtx.DB.QueryRowContext(dbCtx.Ctx, selectSQL, messageId))
+}
+
+func InsertMessageEdit(dbCtx *db.DbCtx, edit *model.MessageEdit) error {
+	insertSQL := `
+		INSERT INTO MESSAGE_EDIT_HISTORY
+			(
+				message_id,
+				body,
+				edited_by,
+				edited_at
+			)
+		VALUES
+			(?,?,?,?)
+	`
+	_, err := dbCtx.ExecContext(insertSQL,
+		edit.MessageId,
+		edit.Body,
+		edit.EditedBy,
+		edit.EditedAt,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert message edit: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func UpdateMessageBody(dbCtx *db.DbCtx, messageId int64, body string, mentionAll string, editedAt time.Time) error {
+	updateSQL := `
+		UPDATE MESSAGE
+			SET body = ?,
+			    mention_all = NULLIF(?, ''),
+			    updated_at = ?
+		WHERE id = ?
+		  AND deleted_at IS NULL
+	`
+	_, err := dbCtx.ExecContext(updateSQL, body, mentionAll, editedAt, messageId)
+	if err != nil {
+		log.Error().Msgf("Failed to update message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMessage turns the message into a tombstone. Its body is kept in MESSAGE_EDIT_HISTORY.
+func DeleteMessage(dbCtx *db.DbCtx, messageId int64, deletedBy int64, deletedAt time.Time) error {
+	updateSQL := `
+		UPDATE MESSAGE
+			SET body = '',
+			    deleted_at = ?,
+			    deleted_by = ?
+		WHERE id = ?
+		  AND deleted_at IS NULL
+	`
+	_, err := dbCtx.ExecContext(updateSQL, deletedAt, deletedBy, messageId)
+	if err != nil {
+		log.Error().Msgf("Failed to delete message: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func FetchMessageEdits(dbCtx *db.DbCtx, messageId int64) ([]model.MessageEdit, error) {
+	selectSQL := `
+		SELECT
+			message_id,
+			body,
+			edited_by,
+			edited_at
+		FROM MESSAGE_EDIT_HISTORY
+		WHERE message_id = ?
+		ORDER BY id
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, messageId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch message edits: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []model.MessageEdit{}
+	for rows.Next() {
+		var edit model.MessageEdit
+		err := rows.Scan(
+			&edit.MessageId,
+			&edit.Body,
+			&edit.EditedBy,
+			&edit.EditedAt,
+		)
+		if err != nil {
+			log.Error().Msgf("Failed to scan message edit: %v", err)
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, rows.Err()
+}
+
+// IncrementReplyCount counts a new reply on the first message of a thread
+func IncrementReplyCount(dbCtx *db.DbCtx, parentId int64, repliedAt time.Time) error {
+	updateSQL := `
+		UPDATE MESSAGE
+			SET reply_count = reply_count + 1,
+			    last_reply_at = ?
+		WHERE id = ?
+	`
+	_, err := dbCtx.ExecContext(updateSQL, repliedAt, parentId)
+	if err != nil {
+		log.Error().Msgf("Failed to increment reply count: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// FetchThreadParticipants returns the accounts that wrote the first message or a reply of a thread
+// and are still members of its room
+func FetchThreadParticipants(dbCtx *db.DbCtx, parentId int64) ([]int64, error) {
+	selectSQL := `
+		SELECT DISTINCT m.account_id
+		FROM MESSAGE m
+		JOIN ROOM_MEMBER rm ON rm.room_id = m.room_id AND rm.account_id = m.account_id
+		WHERE m.id = ?
+		   OR m.parent_id = ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, parentId, parentId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch thread participants: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	accountIds := []int64{}
+	for rows.Next() {
+		var accountId int64
+		if err := rows.Scan(&accountId); err != nil {
+			return nil, err
+		}
+		accountIds = append(accountIds, accountId)
+	}
+
+	return accountIds, rows.Err()
+}
+
+// FetchMessagesAfterSeq returns up to limit messages of the room with a sequence number
+// greater than afterSeq, oldest first
+func FetchMessagesAfterSeq(dbCtx *db.DbCtx, roomId string, afterSeq int64, limit int) ([]model.Message, error) {
+	selectSQL := `
+		SELECT` + messageColumns + `
+		FROM MESSAGE
+		WHERE room_id = ?
+		  AND seq > ?
+		ORDER BY seq
+		LIMIT ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, roomId, afterSeq, limit)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch messages after seq: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []model.Message{}
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			log.Error().Msgf("Failed to scan message: %v", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// FetchMessagesByIds returns the messages of the room among the given ids, in sequence order
+func FetchMessagesByIds(dbCtx *db.DbCtx, roomId string, messageIds []int64) ([]model.Message, error) {
+	messages := []model.Message{}
+	if len(messageIds) == 0 {
+		return messages, nil
+	}
+
+	args := make([]interface{}, 0, len(messageIds)+1)
+	args = append(args, roomId)
+	for _, messageId := range messageIds {
+		args = append(args, messageId)
+	}
+
+	selectSQL := `
+		SELECT` + messageColumns + `
+		FROM MESSAGE
+		WHERE room_id = ?
+		  AND id IN (?` + strings.Repeat(",?", len(messageIds)-1) + `)
+		ORDER BY seq, id
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch messages by ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			log.Error().Msgf("Failed to scan message: %v", err)
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
@@ -0,0 +1,132 @@
+package repo
+
+import (
+	"strings"
+
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InsertReaction adds the emoji of the account to the message.
+// It returns false if the account already reacted with the emoji.
+func InsertReaction(dbCtx *db.DbCtx, messageId int64, accountId int64, emoji string) (bool, error) {
+	insertSQL := `
+		INSERT IGNORE INTO MESSAGE_REACTION
+			(
+				message_id,
+				account_id,
+				emoji,
+				created_at
+			)
+		VALUES
+			(?,?,?,current_timestamp(3))
+	`
+	result, err := dbCtx.ExecContext(insertSQL, messageId, accountId, emoji)
+	if err != nil {
+		log.Error().Msgf("Failed to insert reaction: %v", err)
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+// DeleteReaction removes the emoji of the account from the message.
+// It returns false if the account had not reacted with the emoji.
+func DeleteReaction(dbCtx *db.DbCtx, messageId int64, accountId int64, emoji string) (bool, error) {
+	deleteSQL := `
+		DELETE FROM MESSAGE_REACTION
+		WHERE message_id = ?
+		  AND account_id = ?
+		  AND emoji = ?
+	`
+	result, err := dbCtx.ExecContext(deleteSQL, messageId, accountId, emoji)
+	if err != nil {
+		log.Error().Msgf("Failed to delete reaction: %v", err)
+		return false, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return deleted > 0, nil
+}
+
+func CountReaction(dbCtx *db.DbCtx, messageId int64, emoji string) (int, error) {
+	selectSQL := `
+		SELECT COUNT(*)
+		FROM MESSAGE_REACTION
+		WHERE message_id = ?
+		  AND emoji = ?
+	`
+	var count int
+	err := dbCtx.DB.QueryRowContext(dbCtx.Ctx, selectSQL, messageId, emoji).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// FetchReactions returns the reaction aggregates of the messages by message id,
+// each emoji in the order it was first used
+func FetchReactions(dbCtx *db.DbCtx, messageIds []int64) (map[int64][]model.Reaction, error) {
+	reactions := make(map[int64][]model.Reaction)
+	if len(messageIds) == 0 {
+		return reactions, nil
+	}
+
+	args := make([]interface{}, 0, len(messageIds))
+	for _, messageId := range messageIds {
+		args = append(args, messageId)
+	}
+
+	selectSQL := `
+		SELECT
+			message_id,
+			emoji,
+			account_id
+		FROM MESSAGE_REACTION
+		WHERE message_id IN (?` + strings.Repeat(",?", len(messageIds)-1) + `)
+		ORDER BY message_id, created_at
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch reactions: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageId, accountId int64
+		var emoji string
+		err := rows.Scan(&messageId, &emoji, &accountId)
+		if err != nil {
+			log.Error().Msgf("Failed to scan reaction: %v", err)
+			return nil, err
+		}
+
+		messageReactions := reactions[messageId]
+		index := -1
+		for i := range messageReactions {
+			if messageReactions[i].Emoji == emoji {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			messageReactions = append(messageReactions, model.Reaction{Emoji: emoji, AccountIds: []int64{}})
+			index = len(messageReactions) - 1
+		}
+		messageReactions[index].Count++
+		messageReactions[index].AccountIds = append(messageReactions[index].AccountIds, accountId)
+		reactions[messageId] = messageReactions
+	}
+
+	return reactions, rows.Err()
+}
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"errors"
+	"strconv"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+func unackedKey(roomId string, accountId int64) string {
+	return constants.UnackedKey + roomId + "_" + strconv.FormatInt(accountId, 10)
+}
+
+// SaveUnackedMessages keeps the ids of the messages sent to a closed connection of the account
+// that it never acknowledged, until its next connection to the room
+func SaveUnackedMessages(roomId string, accountId int64, messageIds []int64, localCtx *model.LocalCtx) error {
+	if len(messageIds) == 0 {
+		return nil
+	}
+
+	members := make([]interface{}, 0, len(messageIds))
+	for _, messageId := range messageIds {
+		members = append(members, messageId)
+	}
+
+	key := unackedKey(roomId, accountId)
+	err := localCtx.RedisCtx.SAdd(key, members...)
+	if err != nil {
+		return err
+	}
+	return localCtx.RedisCtx.Expire(key, constants.UnackedExpire)
+}
+
+// popSetScript returns the members of a set and deletes it, so ids saved meanwhile are never lost
+const popSetScript = `
+local members = redis.call('SMEMBERS', KEYS[1])
+redis.call('DEL', KEYS[1])
+return members
+`
+
+// PopUnackedMessages returns and forgets the unacknowledged message ids of the account in the room
+func PopUnackedMessages(roomId string, accountId int64, localCtx *model.LocalCtx) ([]int64, error) {
+	key := unackedKey(roomId, accountId)
+	result, err := localCtx.RedisCtx.Eval(popSetScript, []string{key})
+	if err != nil {
+		return nil, err
+	}
+	members, ok := result.([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected pop set script result")
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	messageIds := make([]int64, 0, len(members))
+	for _, member := range members {
+		value, _ := member.(string)
+		messageId, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		messageIds = append(messageIds, messageId)
+	}
+	return messageIds, nil
+}
@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+)
+
+// TestPopUnackedMessages runs against a local redis-server, e.g. REDIS_ADDR=localhost:6379
+func TestPopUnackedMessages(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR is not set")
+	}
+	rds := redis.NewClient(&redis.Options{Addr: addr})
+	defer rds.Close()
+	localCtx := &model.LocalCtx{AccountId: 1, RedisCtx: &db.RedisCtx{Rds: rds, Ctx: context.Background()}}
+
+	roomId := "test_" + time.Now().Format(time.RFC3339Nano)
+	if err := SaveUnackedMessages(roomId, 1, []int64{3, 1, 2}, localCtx); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	messageIds, err := PopUnackedMessages(roomId, 1, localCtx)
+	if err != nil {
+		t.Fatalf("pop: %v", err)
+	}
+	sort.Slice(messageIds, func(i, j int) bool { return messageIds[i] < messageIds[j] })
+	if len(messageIds) != 3 || messageIds[0] != 1 || messageIds[2] != 3 {
+		t.Fatalf("popped %v", messageIds)
+	}
+
+	messageIds, err = PopUnackedMessages(roomId, 1, localCtx)
+	if err != nil || len(messageIds) != 0 {
+		t.Fatalf("second pop returned %v, %v", messageIds, err)
+	}
+}
@@ -0,0 +1,167 @@
+package repo
+
+import (
+	"strconv"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+func presenceMemberKey(roomId string, accountId int64) string {
+	return roomId + "_" + strconv.FormatInt(accountId, 10)
+}
+
+// IncrPresence counts a new live connection of the account to the room and returns the count
+func IncrPresence(roomId string, accountId int64, localCtx *model.LocalCtx) (int64, error) {
+	count, err := localCtx.RedisCtx.Incr(constants.PresenceCountKey + presenceMemberKey(roomId, accountId))
+	if err != nil {
+		log.Error().Msgf("Failed to incr presence: %v", err)
+		return 0, err
+	}
+
+	_, err = localCtx.RedisCtx.Incr(constants.PresenceAccountKey + strconv.FormatInt(accountId, 10))
+	if err != nil {
+		log.Error().Msgf("Failed to incr account presence: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// DecrPresence removes a live connection of the account to the room and returns the remaining count.
+// The presence keys are removed with the last connection.
+func DecrPresence(roomId string, accountId int64, localCtx *model.LocalCtx) (int64, error) {
+	memberKey := presenceMemberKey(roomId, accountId)
+	accountKey := constants.PresenceAccountKey + strconv.FormatInt(accountId, 10)
+	accountCount, err := localCtx.RedisCtx.Decr(accountKey)
+	if err != nil {
+		log.Error().Msgf("Failed to decr account presence: %v", err)
+		return 0, err
+	}
+	if accountCount <= 0 {
+		localCtx.RedisCtx.Del(accountKey)
+	}
+
+	count, err := localCtx.RedisCtx.Decr(constants.PresenceCountKey + memberKey)
+	if err != nil {
+		log.Error().Msgf("Failed to decr presence: %v", err)
+		return 0, err
+	}
+	if count > 0 {
+		return count, nil
+	}
+
+	localCtx.RedisCtx.Del(constants.PresenceCountKey + memberKey)
+	localCtx.RedisCtx.Del(constants.PresenceStatusKey + memberKey)
+	err = localCtx.RedisCtx.ZRem(constants.PresenceRoomKey+roomId, strconv.FormatInt(accountId, 10))
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// SetPresenceStatus stores the status and extends every presence key by the ttl.
+// Keys left by a crashed instance expire once nobody refreshes them.
+func SetPresenceStatus(roomId string, accountId int64, status string, ttl time.Duration, localCtx *model.LocalCtx) error {
+	memberKey := presenceMemberKey(roomId, accountId)
+
+	err := localCtx.RedisCtx.SetWithExpire(constants.PresenceStatusKey+memberKey, status, ttl)
+	if err != nil {
+		log.Error().Msgf("Failed to set presence status: %v", err)
+		return err
+	}
+
+	err = localCtx.RedisCtx.Expire(constants.PresenceCountKey+memberKey, ttl)
+	if err != nil {
+		return err
+	}
+
+	err = localCtx.RedisCtx.Expire(constants.PresenceAccountKey+strconv.FormatInt(accountId, 10), ttl)
+	if err != nil {
+		return err
+	}
+
+	expireAt := float64(time.Now().Add(ttl).UnixMilli())
+	return localCtx.RedisCtx.ZAdd(constants.PresenceRoomKey+roomId, expireAt, strconv.FormatInt(accountId, 10))
+}
+
+// GetPresenceStatus returns the status of the account in the room, or offline
+func GetPresenceStatus(roomId string, accountId int64, localCtx *model.LocalCtx) string {
+	status, err := localCtx.RedisCtx.Get(constants.PresenceStatusKey + presenceMemberKey(roomId, accountId))
+	if err != nil || status == "" {
+		return constants.PresenceOffline
+	}
+	return status
+}
+
+// FetchRoomPresence returns the accounts present in the room and drops the expired ones
+func FetchRoomPresence(roomId string, localCtx *model.LocalCtx) ([]model.Presence, error) {
+	roomKey := constants.PresenceRoomKey + roomId
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	err := localCtx.RedisCtx.ZRemRangeByScore(roomKey, "-inf", "("+now)
+	if err != nil {
+		log.Error().Msgf("Failed to remove expired presence: %v", err)
+		return nil, err
+	}
+
+	members, err := localCtx.RedisCtx.ZRangeByScore(roomKey, now, "+inf")
+	if err != nil {
+		log.Error().Msgf("Failed to fetch presence: %v", err)
+		return nil, err
+	}
+
+	presences := []model.Presence{}
+	if len(members) == 0 {
+		return presences, nil
+	}
+
+	statusKeys := make([]string, 0, len(members))
+	for _, member := range members {
+		statusKeys = append(statusKeys, constants.PresenceStatusKey+roomId+"_"+member)
+	}
+	statuses, err := localCtx.RedisCtx.MGet(statusKeys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, member := range members {
+		status, ok := statuses[i].(string)
+		if !ok {
+			continue
+		}
+		accountId, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		presences = append(presences, model.Presence{AccountId: accountId, Status: status})
+	}
+	return presences, nil
+}
+
+// FetchConnectedAccounts returns which of the accounts have a live connection to any room
+func FetchConnectedAccounts(accountIds []int64, localCtx *model.LocalCtx) (map[int64]bool, error) {
+	connected := make(map[int64]bool)
+	if len(accountIds) == 0 {
+		return connected, nil
+	}
+
+	keys := make([]string, 0, len(accountIds))
+	for _, accountId := range accountIds {
+		keys = append(keys, constants.PresenceAccountKey+strconv.FormatInt(accountId, 10))
+	}
+	counts, err := localCtx.RedisCtx.MGet(keys...)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch connected accounts: %v", err)
+		return nil, err
+	}
+
+	for i, accountId := range accountIds {
+		value, _ := counts[i].(string)
+		count, _ := strconv.ParseInt(value, 10, 64)
+		connected[accountId] = count > 0
+	}
+	return connected, nil
+}
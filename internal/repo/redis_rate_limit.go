@@ -0,0 +1,72 @@
+package repo
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+// takeTokenScript refills the bucket for the time elapsed on the redis clock and takes a token.
+// It returns 1 and 0 when the token was taken, 0 and the milliseconds until the next token otherwise.
+const takeTokenScript = `
+local burst = tonumber(ARGV[1])
+local refill = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + (now - ts) / refill)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * refill)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], burst * refill + 1000)
+return {allowed, retry}
+`
+
+// TakeToken takes a token from the bucket of the given name and id, shared by every instance.
+// It returns 0 when the token was taken, or how long until the bucket holds one again.
+func TakeToken(name string, id string, limit model.RateLimit, localCtx *model.LocalCtx) (time.Duration, error) {
+	key := constants.RateLimitKey + name + "_" + id
+	result, err := localCtx.RedisCtx.Eval(takeTokenScript, []string{key}, limit.Burst, limit.Refill.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, errors.New("unexpected rate limit script result")
+	}
+	allowed, _ := values[0].(int64)
+	retryMs, _ := values[1].(int64)
+	if allowed == 1 {
+		return 0, nil
+	}
+	return time.Duration(retryMs) * time.Millisecond, nil
+}
+
+// IncrRateLimitStrike counts a limited frame of the account and returns the strikes of the
+// current window
+func IncrRateLimitStrike(accountId int64, localCtx *model.LocalCtx) (int64, error) {
+	key := constants.RateLimitStrikeKey + strconv.FormatInt(accountId, 10)
+	strikes, err := localCtx.RedisCtx.Incr(key)
+	if err != nil {
+		return 0, err
+	}
+	if strikes == 1 {
+		err = localCtx.RedisCtx.Expire(key, constants.RateLimitStrikeWindow)
+	}
+	return strikes, err
+}
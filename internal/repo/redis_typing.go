@@ -0,0 +1,21 @@
+package repo
+
+import (
+	"strconv"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+)
+
+// AcquireTypingSlot returns true if no typing event of the account was relayed to the room
+// during the last throttle period
+func AcquireTypingSlot(roomId string, accountId int64, throttle time.Duration, localCtx *model.LocalCtx) (bool, error) {
+	key := constants.TypingKey + roomId + "_" + strconv.FormatInt(accountId, 10)
+	return localCtx.RedisCtx.SetNX(key, "1", throttle)
+}
+
+// ReleaseTypingSlot lets the next typing event of the account through immediately
+func ReleaseTypingSlot(roomId string, accountId int64, localCtx *model.LocalCtx) error {
+	return localCtx.RedisCtx.Del(constants.TypingKey + roomId + "_" + strconv.FormatInt(accountId, 10))
+}
@@ -0,0 +1,206 @@
+package repo
+
+import (
+	"database/sql"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// InsertRoomMember adds the account to the room. It returns false if the account was already a member.
+func InsertRoomMember(dbCtx *db.DbCtx, roomId string, accountId int64, role int) (bool, error) {
+	insertSQL := `
+		INSERT IGNORE INTO ROOM_MEMBER
+			(
+				room_id,
+				account_id,
+				role,
+				created_at,
+				created_by
+			)
+		VALUES
+			(?,?,?,current_timestamp(3),?)
+	`
+	result, err := dbCtx.ExecContext(insertSQL,
+		roomId,
+		accountId,
+		role,
+		constants.ServerName,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to insert room member: %v", err)
+		return false, err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+func DeleteRoomMember(dbCtx *db.DbCtx, roomId string, accountId int64) error {
+	deleteSQL := `
+		DELETE FROM ROOM_MEMBER
+		WHERE room_id = ?
+		  AND account_id = ?
+	`
+	_, err := dbCtx.ExecContext(deleteSQL, roomId, accountId)
+	if err != nil {
+		log.Error().Msgf("Failed to delete room member: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateRoomMemberRole changes the role of the member. It returns false if the account is not a member.
+func UpdateRoomMemberRole(dbCtx *db.DbCtx, roomId string, accountId int64, role int) (bool, error) {
+	updateSQL := `
+		UPDATE ROOM_MEMBER
+			SET role = ?
+		WHERE room_id = ?
+		  AND account_id = ?
+	`
+	result, err := dbCtx.ExecContext(updateSQL, role, roomId, accountId)
+	if err != nil {
+		log.Error().Msgf("Failed to update room member role: %v", err)
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// GetRoomMemberRole returns the role of the account in the room, or 0 if it is not a member
+func GetRoomMemberRole(dbCtx *db.DbCtx, roomId string, accountId int64) (int, error) {
+	selectSQL := `
+		SELECT role
+		FROM ROOM_MEMBER
+		WHERE room_id = ?
+		  AND account_id = ?
+	`
+	var role int
+	err := dbCtx.DB.QueryRowContext(dbCtx.Ctx, selectSQL, roomId, accountId).Scan(&role)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return role, nil
+}
+
+func FetchRoomMembers(dbCtx *db.DbCtx, roomId string) ([]model.RoomMember, error) {
+	selectSQL := `
+		SELECT
+			m.room_id,
+			m.account_id,
+			a.user_id,
+			m.role,
+			m.last_read_message_id,
+			m.created_at
+		FROM ROOM_MEMBER m
+		JOIN ACCOUNT a ON a.id = m.account_id
+		WHERE m.room_id = ?
+		ORDER BY m.created_at
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, roomId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch room members: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []model.RoomMember{}
+	for rows.Next() {
+		var member model.RoomMember
+		err := rows.Scan(
+			&member.RoomId,
+			&member.AccountId,
+			&member.UserId,
+			&member.Role,
+			&member.LastReadMessageId,
+			&member.JoinedAt,
+		)
+		if err != nil {
+			log.Error().Msgf("Failed to scan room member: %v", err)
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// UpdateLastReadMessage moves the read marker of the member forward to messageId.
+// It returns false if the marker did not move, e.g. the message is older than the marker,
+// does not belong to the room or the account is not a member.
+func UpdateLastReadMessage(dbCtx *db.DbCtx, roomId string, accountId int64, messageId int64) (bool, error) {
+	updateSQL := `
+		UPDATE ROOM_MEMBER
+			SET last_read_message_id = ?
+		WHERE room_id = ?
+		  AND account_id = ?
+		  AND last_read_message_id < ?
+		  AND EXISTS(
+		      SELECT 1
+		      FROM MESSAGE
+		      WHERE id = ?
+		        AND room_id = ?
+		  )
+	`
+	result, err := dbCtx.ExecContext(updateSQL,
+		messageId,
+		roomId,
+		accountId,
+		messageId,
+		messageId,
+		roomId,
+	)
+	if err != nil {
+		log.Error().Msgf("Failed to update last read message: %v", err)
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// FetchMemberRoomIds returns the rooms the account is a member of, direct message rooms included
+func FetchMemberRoomIds(dbCtx *db.DbCtx, accountId int64) ([]string, error) {
+	selectSQL := `
+		SELECT m.room_id
+		FROM ROOM_MEMBER m
+		JOIN CHATING_ROOM r ON r.id = m.room_id
+		WHERE m.account_id = ?
+		  AND r.is_used = 1
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, accountId)
+	if err != nil {
+		log.Error().Msgf("Failed to fetch member room ids: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	roomIds := []string{}
+	for rows.Next() {
+		var roomId string
+		if err := rows.Scan(&roomId); err != nil {
+			return nil, err
+		}
+		roomIds = append(roomIds, roomId)
+	}
+
+	return roomIds, rows.Err()
+}
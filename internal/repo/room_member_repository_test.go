@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+)
+
+// testDbCtx connects to a mysql database with the schema of sql/ applied, e.g.
+// MYSQL_DSN="root:secret@tcp(localhost:3306)/chating?parseTime=true"
+func testDbCtx(t *testing.T) *db.DbCtx {
+	dsn := os.Getenv("MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_DSN is not set")
+	}
+	pool, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	if err := pool.Ping(); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	return &db.DbCtx{DB: pool, Ctx: context.Background()}
+}
+
+func insertTestRoom(t *testing.T, dbCtx *db.DbCtx, ownerId int64) string {
+	room := &model.ChatingRoom{
+		RoomId:   "test_" + time.Now().Format("20060102150405.000000000"),
+		RoomName: "test room",
+		OwnerId:  ownerId,
+		IsUsed:   true,
+	}
+	if err := InsertChatingRoom(dbCtx, room); err != nil {
+		t.Fatalf("insert room: %v", err)
+	}
+	t.Cleanup(func() {
+		dbCtx.RunInTxn(func() error {
+			return DeleteChatingRoom(dbCtx, room.RoomId)
+		})
+	})
+	if _, err := InsertRoomMember(dbCtx, room.RoomId, ownerId, constants.RoomRoleOwner); err != nil {
+		t.Fatalf("insert member: %v", err)
+	}
+	return room.RoomId
+}
+
+func TestFetchMemberRoomIds(t *testing.T) {
+	dbCtx := testDbCtx(t)
+	accountId := time.Now().UnixNano()
+	roomId := insertTestRoom(t, dbCtx, accountId)
+
+	roomIds, err := FetchMemberRoomIds(dbCtx, accountId)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(roomIds) != 1 || roomIds[0] != roomId {
+		t.Fatalf("expected [%s], got %v", roomId, roomIds)
+	}
+}
+
+func TestNextRoomSeq(t *testing.T) {
+	dbCtx := testDbCtx(t)
+	roomId := insertTestRoom(t, dbCtx, time.Now().UnixNano())
+
+	for want := int64(1); want <= 2; want++ {
+		seq, err := NextRoomSeq(dbCtx, roomId)
+		if err != nil {
+			t.Fatalf("next seq: %v", err)
+		}
+		if seq != want {
+			t.Fatalf("expected seq %d, got %d", want, seq)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package repo
+
+import (
+	"strings"
+
+	"chating_service/internal/db"
+	"chating_service/internal/model"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SearchMessages returns the messages of the rooms that contain every term, newest first.
+// It relies on the FULLTEXT index of MESSAGE.body.
+func SearchMessages(dbCtx *db.DbCtx, query model.SearchQuery) ([]model.SearchHit, error) {
+	hits := []model.SearchHit{}
+	if len(query.Terms) == 0 || len(query.RoomIds) == 0 {
+		return hits, nil
+	}
+
+	// the terms only hold letters and digits, so they are safe as boolean mode operands
+	against := make([]string, 0, len(query.Terms))
+	for _, term := range query.Terms {
+		against = append(against, `+"`+term+`"`)
+	}
+
+	args := []interface{}{strings.Join(against, " ")}
+	for _, roomId := range query.RoomIds {
+		args = append(args, roomId)
+	}
+
+	conditions := ""
+	if !query.From.IsZero() {
+		conditions += " AND created_at >= ?"
+		args = append(args, query.From)
+	}
+	if !query.Before.IsZero() {
+		conditions += " AND created_at < ?"
+		args = append(args, query.Before)
+	}
+	if query.BeforeId > 0 {
+		conditions += " AND id < ?"
+		args = append(args, query.BeforeId)
+	}
+	args = append(args, query.Limit)
+
+	selectSQL := `
+		SELECT
+			id,
+			room_id,
+			account_id,
+			body,
+			created_at
+		FROM MESSAGE
+		WHERE MATCH(body) AGAINST(? IN BOOLEAN MODE)
+		  AND room_id IN (?` + strings.Repeat(",?", len(query.RoomIds)-1) + `)
+		  AND deleted_at IS NULL` + conditions + `
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := dbCtx.DB.QueryContext(dbCtx.Ctx, selectSQL, args...)
+	if err != nil {
+		log.Error().Msgf("Failed to search messages: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit model.SearchHit
+		err := rows.Scan(&hit.MessageId, &hit.RoomId, &hit.AccountId, &hit.Body, &hit.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
@@ -3,28 +3,34 @@ package router
 import (
 	"net/http"
 
+	"chating_service/internal/config"
 	"chating_service/internal/constants"
 	"chating_service/internal/controller"
 	"chating_service/internal/db"
 	"chating_service/internal/model"
+	"chating_service/internal/notifier"
+	"chating_service/internal/pubsub"
+	"chating_service/internal/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func InitRoute(router *gin.Engine, autoMiddleware gin.HandlerFunc) {
+// InitRoute registers every route and returns the websocket hub they share
+func InitRoute(router *gin.Engine, autoMiddleware gin.HandlerFunc) *controller.Hub {
 	router.NoRoute(func(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{})
 	})
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
 		AllowCredentials: true,
 	}))
 
-	hub := controller.NewHub()
+	pushNotifier := notifier.NewNotifier(config.GetAppConfig().Push)
+	hub := controller.NewHub(pubsub.NewRedisBroker(db.GetRedisClient()), pushNotifier, config.GetAppConfig().Websocket)
 	go hub.Run()
 
 	routerGrout := router.Group("/api")
@@ -33,6 +39,44 @@ func InitRoute(router *gin.Engine, autoMiddleware gin.HandlerFunc) {
 		routerGrout.POST("/", controller.RdsTest)
 
 		routerGrout.GET("/chating_room", controller.GetChatingRoom)
+		routerGrout.POST("/chating_room", controller.CreateChatingRoom)
+		routerGrout.PATCH("/chating_room/:roomId", func(ctx *gin.Context) {
+			controller.UpdateChatingRoom(hub, ctx)
+		})
+		routerGrout.DELETE("/chating_room/:roomId", func(ctx *gin.Context) {
+			controller.DeleteChatingRoom(hub, ctx)
+		})
+		routerGrout.GET("/chating_room/:roomId/messages", controller.GetMessages)
+		routerGrout.PATCH("/chating_room/:roomId/messages/:messageId", func(ctx *gin.Context) {
+			controller.UpdateMessage(hub, ctx)
+		})
+		routerGrout.DELETE("/chating_room/:roomId/messages/:messageId", func(ctx *gin.Context) {
+			controller.DeleteMessage(hub, ctx)
+		})
+		routerGrout.GET("/chating_room/:roomId/messages/:messageId/history", controller.GetMessageEdits)
+		routerGrout.GET("/chating_room/:roomId/messages/:messageId/replies", controller.GetThreadReplies)
+		routerGrout.POST("/chating_room/:roomId/attachments", controller.CreateAttachment)
+		routerGrout.GET("/search", controller.SearchMessages)
+		routerGrout.POST("/chating_room/:roomId/messages/:messageId/reactions", func(ctx *gin.Context) {
+			controller.AddReaction(hub, ctx)
+		})
+		routerGrout.DELETE("/chating_room/:roomId/messages/:messageId/reactions/:emoji", func(ctx *gin.Context) {
+			controller.RemoveReaction(hub, ctx)
+		})
+		routerGrout.GET("/chating_room/:roomId/members", controller.GetRoomMembers)
+		routerGrout.PATCH("/chating_room/:roomId/members/:accountId", controller.ChangeMemberRole)
+		routerGrout.GET("/chating_room/:roomId/presence", controller.GetRoomPresence)
+		routerGrout.POST("/chating_room/:roomId/join", controller.JoinRoom)
+		routerGrout.POST("/chating_room/:roomId/leave", func(ctx *gin.Context) {
+			controller.LeaveRoom(hub, ctx)
+		})
+		routerGrout.POST("/chating_room/:roomId/invite", controller.InviteMember)
+		routerGrout.POST("/chating_room/:roomId/kick", func(ctx *gin.Context) {
+			controller.KickMember(hub, ctx)
+		})
+
+		routerGrout.GET("/dm", controller.GetDirectConversations)
+		routerGrout.POST("/dm", controller.OpenDirectRoom)
 
 	}
 
@@ -40,15 +84,26 @@ func InitRoute(router *gin.Engine, autoMiddleware gin.HandlerFunc) {
 		controller.WebsocketHandler(hub, c)
 	})
 
-	router.POST("/login", func(ctx *gin.Context) {
+	// signed urls of the local storage point at this server
+	if localStorage, ok := storage.GetStorage().(*storage.LocalStorage); ok {
+		router.PUT(storage.LocalStorageRoute+"*objectKey", func(ctx *gin.Context) {
+			controller.UploadLocalFile(localStorage, ctx)
+		})
+		router.GET(storage.LocalStorageRoute+"*objectKey", func(ctx *gin.Context) {
+			controller.DownloadLocalFile(localStorage, ctx)
+		})
+	}
+
+	router.POST("/login", controller.LoginRateLimit(), func(ctx *gin.Context) {
 		controller.LoginHandler(ctx, autoMiddleware)
 	})
-	router.POST("/refresh_token", func(ctx *gin.Context) {
+	router.POST("/refresh_token", controller.RefreshTokenRateLimit(), func(ctx *gin.Context) {
 		controller.RefreshTokenHandler(ctx, autoMiddleware)
 	})
 	router.POST("/logout", func(ctx *gin.Context) {
 
 	})
+	return hub
 }
 
 func localCtxMiddleware() gin.HandlerFunc {
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"sort"
+	"sync"
+
+	"chating_service/internal/model"
+)
+
+// MemorySearcher is an inverted index of the messages saved since the process started.
+// It suits a single instance or development setup; terms match whole words.
+type MemorySearcher struct {
+	mu       sync.RWMutex
+	postings map[string]map[int64]struct{} // term -> message ids
+	messages map[int64]model.SearchHit
+	terms    map[int64][]string // message id -> indexed terms
+}
+
+func NewMemorySearcher() *MemorySearcher {
+	return &MemorySearcher{
+		postings: make(map[string]map[int64]struct{}),
+		messages: make(map[int64]model.SearchHit),
+		terms:    make(map[int64][]string),
+	}
+}
+
+func (s *MemorySearcher) Index(message model.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(message.Id)
+	if message.Deleted {
+		return
+	}
+
+	terms := indexTerms(message.Body)
+	for _, term := range terms {
+		if s.postings[term] == nil {
+			s.postings[term] = make(map[int64]struct{})
+		}
+		s.postings[term][message.Id] = struct{}{}
+	}
+	s.terms[message.Id] = terms
+	s.messages[message.Id] = model.SearchHit{
+		MessageId: message.Id,
+		RoomId:    message.RoomId,
+		AccountId: message.AccountId,
+		Body:      message.Body,
+		CreatedAt: message.CreatedAt,
+	}
+}
+
+func (s *MemorySearcher) Remove(messageId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(messageId)
+}
+
+func (s *MemorySearcher) remove(messageId int64) {
+	for _, term := range s.terms[messageId] {
+		delete(s.postings[term], messageId)
+		if len(s.postings[term]) == 0 {
+			delete(s.postings, term)
+		}
+	}
+	delete(s.terms, messageId)
+	delete(s.messages, messageId)
+}
+
+func (s *MemorySearcher) Search(localCtx *model.LocalCtx, query model.SearchQuery) ([]model.SearchHit, error) {
+	hits := []model.SearchHit{}
+	if len(query.Terms) == 0 || len(query.RoomIds) == 0 {
+		return hits, nil
+	}
+
+	rooms := make(map[string]bool, len(query.RoomIds))
+	for _, roomId := range query.RoomIds {
+		rooms[roomId] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// walk the rarest term and check the others
+	rarest := query.Terms[0]
+	for _, term := range query.Terms[1:] {
+		if len(s.postings[term]) < len(s.postings[rarest]) {
+			rarest = term
+		}
+	}
+
+	for messageId := range s.postings[rarest] {
+		hit := s.messages[messageId]
+		if !rooms[hit.RoomId] || !s.matchesAll(messageId, query.Terms) {
+			continue
+		}
+		if query.BeforeId > 0 && messageId >= query.BeforeId {
+			continue
+		}
+		if !query.From.IsZero() && hit.CreatedAt.Before(query.From) {
+			continue
+		}
+		if !query.Before.IsZero() && !hit.CreatedAt.Before(query.Before) {
+			continue
+		}
+		hits = append(hits, hit)
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].MessageId > hits[j].MessageId })
+	if len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
+
+func (s *MemorySearcher) matchesAll(messageId int64, terms []string) bool {
+	for _, term := range terms {
+		if _, ok := s.postings[term][messageId]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// indexTerms returns every distinct word of a body, unlike Terms which caps a query
+func indexTerms(body string) []string {
+	terms := []string{}
+	seen := make(map[string]bool)
+	for _, word := range splitWords(body) {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
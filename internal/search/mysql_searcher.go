@@ -0,0 +1,18 @@
+package search
+
+import (
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+// MysqlSearcher searches the FULLTEXT index of the MESSAGE table,
+// which mysql keeps up to date by itself
+type MysqlSearcher struct{}
+
+func (MysqlSearcher) Search(localCtx *model.LocalCtx, query model.SearchQuery) ([]model.SearchHit, error) {
+	return repo.SearchMessages(localCtx.RdbCtx, query)
+}
+
+func (MysqlSearcher) Index(message model.Message) {}
+
+func (MysqlSearcher) Remove(messageId int64) {}
@@ -0,0 +1,134 @@
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+
+	"chating_service/internal/config"
+	"chating_service/internal/model"
+)
+
+const (
+	maxTerms       = 10
+	snippetLength  = 160 // characters
+	snippetContext = 40  // characters kept before the first match
+)
+
+// Searcher finds messages by their text.
+// Index and Remove keep an index up to date with saved, edited and deleted messages;
+// searchers that read the database directly ignore them.
+type Searcher interface {
+	Search(localCtx *model.LocalCtx, query model.SearchQuery) ([]model.SearchHit, error)
+	Index(message model.Message)
+	Remove(messageId int64)
+}
+
+var searcher Searcher = MysqlSearcher{}
+
+// InitSearcher creates the searcher selected by the config: "mysql" (the default) or "memory"
+func InitSearcher(searchConfig config.SearchConfig) error {
+	switch searchConfig.Driver {
+	case "", "mysql":
+		searcher = MysqlSearcher{}
+	case "memory":
+		searcher = NewMemorySearcher()
+	default:
+		return errors.New("unknown search driver " + searchConfig.Driver)
+	}
+	return nil
+}
+
+func GetSearcher() Searcher {
+	return searcher
+}
+
+// Terms splits a query text into its distinct lowercased words
+func Terms(text string) []string {
+	terms := []string{}
+	seen := make(map[string]bool)
+	for _, term := range splitWords(text) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// splitWords returns the lowercased runs of letters and digits of a text
+func splitWords(text string) []string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+	return words
+}
+
+// Highlight returns the part of the body around the first match of the terms,
+// html escaped and with every match wrapped in <mark>
+func Highlight(body string, terms []string) string {
+	runes := []rune(body)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// marked[i] is true for the characters inside a match
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		termRunes := []rune(term)
+		if len(termRunes) == 0 {
+			continue
+		}
+		for i := 0; i+len(termRunes) <= len(lower); i++ {
+			if string(lower[i:i+len(termRunes)]) != term {
+				continue
+			}
+			for j := i; j < i+len(termRunes); j++ {
+				marked[j] = true
+			}
+			if first < 0 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetContext {
+		start = first - snippetContext
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		text := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			text = "<mark>" + text + "</mark>"
+		}
+		snippet.WriteString(text)
+		i = j
+	}
+	if end < len(runes) {
+		snippet.WriteString("…")
+	}
+	return snippet.String()
+}
@@ -0,0 +1,48 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"chating_service/internal/model"
+)
+
+func TestHighlight(t *testing.T) {
+	snippet := Highlight("We decided to ship <v2> on Friday", Terms("SHIP friday"))
+	expected := "We decided to <mark>ship</mark> &lt;v2&gt; on <mark>Friday</mark>"
+	if snippet != expected {
+		t.Fatalf("unexpected snippet %q", snippet)
+	}
+}
+
+func TestMemorySearcher(t *testing.T) {
+	searcher := NewMemorySearcher()
+	now := time.Now()
+	searcher.Index(model.Message{Id: 1, RoomId: "room-1", Body: "release plan for friday", CreatedAt: now})
+	searcher.Index(model.Message{Id: 2, RoomId: "room-2", Body: "friday release is cancelled", CreatedAt: now})
+	searcher.Index(model.Message{Id: 3, RoomId: "room-1", Body: "the release moved to monday", CreatedAt: now})
+	searcher.Index(model.Message{Id: 4, RoomId: "room-1", Body: "another friday release", CreatedAt: now})
+
+	query := model.SearchQuery{Terms: Terms("Release Friday"), RoomIds: []string{"room-1"}, Limit: 10}
+	hits, err := searcher.Search(nil, query)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(hits) != 2 || hits[0].MessageId != 4 || hits[1].MessageId != 1 {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+
+	query.BeforeId = 4
+	hits, _ = searcher.Search(nil, query)
+	if len(hits) != 1 || hits[0].MessageId != 1 {
+		t.Fatalf("unexpected hits before 4 %+v", hits)
+	}
+
+	searcher.Index(model.Message{Id: 1, RoomId: "room-1", Body: "release plan for saturday", CreatedAt: now})
+	searcher.Remove(4)
+	query.BeforeId = 0
+	hits, _ = searcher.Search(nil, query)
+	if len(hits) != 0 {
+		t.Fatalf("edited and removed messages still match %+v", hits)
+	}
+}
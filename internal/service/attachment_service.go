@@ -0,0 +1,157 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+	"chating_service/internal/storage"
+	"chating_service/internal/utils"
+)
+
+var (
+	ErrInvalidFileSize      = errors.New("invalid file size")
+	ErrInvalidFileExtension = errors.New("file type not allowed")
+	ErrInvalidFileCheckSum  = errors.New("file checksum mismatch")
+	ErrInvalidFileName      = errors.New("invalid file name")
+	ErrAttachmentNotFound   = errors.New("attachment not found")
+	ErrTooManyAttachments   = errors.New("too many attachments")
+)
+
+// CreateAttachmentUpload records a file the account of localCtx is about to upload to the room
+// and returns it with a signed upload url. The file type is taken from its extension.
+func CreateAttachmentUpload(localCtx *model.LocalCtx, roomId string, form model.NewAttachmentForm) (model.StoredAttachment, string, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return model.StoredAttachment{}, "", err
+	}
+
+	fileName := path.Base(strings.ReplaceAll(strings.TrimSpace(form.FileName), "\\", "/"))
+	if fileName == "." || fileName == "/" || len(fileName) > constants.MaxAttachmentFileNameLength {
+		return model.StoredAttachment{}, "", ErrInvalidFileName
+	}
+	extension := strings.ToLower(path.Ext(fileName))
+	contentType, ok := constants.AttachmentExtensions[extension]
+	if !ok {
+		return model.StoredAttachment{}, "", ErrInvalidFileExtension
+	}
+	if form.Size <= 0 || form.Size > constants.MaxAttachmentSize {
+		return model.StoredAttachment{}, "", ErrInvalidFileSize
+	}
+	checksum := strings.ToLower(form.Checksum)
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != 16 {
+		return model.StoredAttachment{}, "", ErrInvalidFileCheckSum
+	}
+
+	objectId, err := utils.NewObjectId()
+	if err != nil {
+		return model.StoredAttachment{}, "", err
+	}
+
+	attachment := model.StoredAttachment{
+		Attachment: model.Attachment{
+			ObjectKey:   "attachments/" + roomId + "/" + objectId + extension,
+			FileName:    fileName,
+			ContentType: contentType,
+			Size:        form.Size,
+		},
+		RoomId:    roomId,
+		AccountId: localCtx.AccountId,
+		Checksum:  checksum,
+		CreatedAt: time.Now(),
+	}
+	err = repo.InsertAttachment(localCtx.RdbCtx, &attachment)
+	if err != nil {
+		return model.StoredAttachment{}, "", err
+	}
+
+	uploadUrl, err := storage.GetStorage().SignUploadUrl(attachment.ObjectKey, contentType, constants.AttachmentUrlExpire)
+	if err != nil {
+		return model.StoredAttachment{}, "", err
+	}
+	return attachment, uploadUrl, nil
+}
+
+// resolveAttachments checks the attachments a message of the account of localCtx references.
+// Each one must have been announced by the account for the room, not be sent yet and
+// match the announced size and checksum.
+func resolveAttachments(localCtx *model.LocalCtx, roomId string, objectKeys []string) ([]model.Attachment, error) {
+	if len(objectKeys) > constants.MaxAttachmentsPerMessage {
+		return nil, ErrTooManyAttachments
+	}
+
+	attachments := make([]model.Attachment, 0, len(objectKeys))
+	for _, objectKey := range objectKeys {
+		stored, err := repo.GetAttachment(localCtx.RdbCtx, objectKey)
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if stored.RoomId != roomId || stored.AccountId != localCtx.AccountId || stored.MessageId != 0 {
+			return nil, ErrAttachmentNotFound
+		}
+
+		info, err := storage.GetStorage().Stat(localCtx.RdbCtx.Ctx, objectKey)
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, ErrAttachmentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.Size != stored.Size {
+			return nil, ErrInvalidFileSize
+		}
+		if info.Checksum != stored.Checksum {
+			return nil, ErrInvalidFileCheckSum
+		}
+
+		attachments = append(attachments, stored.Attachment)
+	}
+	return attachments, nil
+}
+
+// signAttachments fills the download urls of the attachments
+func signAttachments(attachments []model.Attachment) error {
+	for i := range attachments {
+		url, err := storage.GetStorage().SignDownloadUrl(attachments[i].ObjectKey, constants.AttachmentUrlExpire)
+		if err != nil {
+			return err
+		}
+		attachments[i].Url = url
+	}
+	return nil
+}
+
+// attachAttachments fills the attachments of the messages with fresh download urls.
+// Tombstones keep no attachments.
+func attachAttachments(localCtx *model.LocalCtx, messages []model.Message) error {
+	messageIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		messageIds = append(messageIds, message.Id)
+	}
+
+	attachments, err := repo.FetchAttachments(localCtx.RdbCtx, messageIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Attachments = attachments[messages[i].Id]
+		if messages[i].Deleted || messages[i].Attachments == nil {
+			messages[i].Attachments = []model.Attachment{}
+		}
+		err := signAttachments(messages[i].Attachments)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
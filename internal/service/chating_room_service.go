@@ -1,14 +1,145 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"chating_service/internal/constants"
 	"chating_service/internal/model"
 	"chating_service/internal/repo"
+	"chating_service/internal/utils"
+)
+
+var (
+	ErrInvalidRoomName        = errors.New("room name is required")
+	ErrRoomNameTooLong        = errors.New("room name too long")
+	ErrRoomDescriptionTooLong = errors.New("room description too long")
 )
 
 func GetChatingRoom(localCtx *model.LocalCtx) ([]model.ChatingRoom, error) {
-	chatingRooms, err := repo.FetchChatingRoom(localCtx.RdbCtx)
+	chatingRooms, err := repo.FetchChatingRoom(localCtx.RdbCtx, localCtx.AccountId)
 	if err != nil {
 		return nil, err
 	}
 	return chatingRooms, nil
 }
+
+func validateRoomFields(roomName string, description string) error {
+	if strings.TrimSpace(roomName) == "" {
+		return ErrInvalidRoomName
+	}
+	if utf8.RuneCountInString(roomName) > constants.MaxRoomNameLength {
+		return ErrRoomNameTooLong
+	}
+	if utf8.RuneCountInString(description) > constants.MaxRoomDescriptionLength {
+		return ErrRoomDescriptionTooLong
+	}
+	return nil
+}
+
+// CreateChatingRoom creates a room owned by the account of localCtx
+func CreateChatingRoom(localCtx *model.LocalCtx, form model.NewChatingRoomForm) (model.ChatingRoom, error) {
+	err := validateRoomFields(form.RoomName, form.Description)
+	if err != nil {
+		return model.ChatingRoom{}, err
+	}
+
+	roomId, err := utils.NewRoomId()
+	if err != nil {
+		return model.ChatingRoom{}, err
+	}
+
+	chatingRoom := model.ChatingRoom{
+		RoomId:      roomId,
+		RoomName:    strings.TrimSpace(form.RoomName),
+		Description: form.Description,
+		OwnerId:     localCtx.AccountId,
+		IsUsed:      true,
+		IsPrivate:   form.IsPrivate,
+	}
+
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		err := repo.InsertChatingRoom(localCtx.RdbCtx, &chatingRoom)
+		if err != nil {
+			return err
+		}
+		_, err = repo.InsertRoomMember(localCtx.RdbCtx, roomId, localCtx.AccountId, constants.RoomRoleOwner)
+		return err
+	})
+	if err != nil {
+		return model.ChatingRoom{}, err
+	}
+	return chatingRoom, nil
+}
+
+// UpdateChatingRoom renames, describes, archives or restores a room.
+// Moderators may change the name and description; only the owner may archive or restore.
+func UpdateChatingRoom(localCtx *model.LocalCtx, roomId string, form model.UpdateChatingRoomForm) (model.ChatingRoom, error) {
+	chatingRoom, err := repo.GetChatingRoom(localCtx.RdbCtx, roomId)
+	if err == sql.ErrNoRows {
+		return chatingRoom, ErrRoomNotFound
+	}
+	if err != nil {
+		return chatingRoom, err
+	}
+
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return chatingRoom, err
+	}
+	if role < constants.RoomRoleModerator {
+		return chatingRoom, ErrPermissionDenied
+	}
+	if form.IsUsed != nil && role != constants.RoomRoleOwner {
+		return chatingRoom, ErrPermissionDenied
+	}
+
+	if form.RoomName != nil {
+		chatingRoom.RoomName = strings.TrimSpace(*form.RoomName)
+	}
+	if form.Description != nil {
+		chatingRoom.Description = *form.Description
+	}
+	if form.IsUsed != nil {
+		chatingRoom.IsUsed = *form.IsUsed
+	}
+
+	err = validateRoomFields(chatingRoom.RoomName, chatingRoom.Description)
+	if err != nil {
+		return chatingRoom, err
+	}
+
+	err = repo.UpdateChatingRoom(localCtx.RdbCtx, &chatingRoom)
+	if err != nil {
+		return chatingRoom, err
+	}
+	return chatingRoom, nil
+}
+
+// DeleteChatingRoom removes a room with its members and history. Only the owner may delete it.
+// It returns the object keys of the attachments of the room, whose objects the caller removes
+// once the room is gone.
+func DeleteChatingRoom(localCtx *model.LocalCtx, roomId string) ([]string, error) {
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	if role != constants.RoomRoleOwner {
+		return nil, ErrPermissionDenied
+	}
+
+	var objectKeys []string
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		objectKeys, err = repo.FetchRoomObjectKeys(localCtx.RdbCtx, roomId)
+		if err != nil {
+			return err
+		}
+		return repo.DeleteChatingRoom(localCtx.RdbCtx, roomId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objectKeys, nil
+}
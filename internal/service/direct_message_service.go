@@ -0,0 +1,61 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+var ErrDirectMessageToSelf = errors.New("cannot open a direct message with yourself")
+
+// directRoomId returns the same room id for a pair of accounts, whichever opens the conversation
+func directRoomId(accountId int64, otherAccountId int64) string {
+	if accountId > otherAccountId {
+		accountId, otherAccountId = otherAccountId, accountId
+	}
+	return fmt.Sprintf("dm_%d_%d", accountId, otherAccountId)
+}
+
+// OpenDirectRoom returns the direct message room between the account of localCtx and another
+// account, creating it on first use. The room is private to the two accounts.
+func OpenDirectRoom(localCtx *model.LocalCtx, accountId int64) (model.ChatingRoom, error) {
+	if accountId == localCtx.AccountId {
+		return model.ChatingRoom{}, ErrDirectMessageToSelf
+	}
+
+	_, err := repo.GetUserAccountByAccountId(localCtx.RdbCtx, accountId)
+	if err == sql.ErrNoRows {
+		return model.ChatingRoom{}, ErrAccountNotFound
+	}
+	if err != nil {
+		return model.ChatingRoom{}, err
+	}
+
+	roomId := directRoomId(localCtx.AccountId, accountId)
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		err := repo.InsertDirectRoom(localCtx.RdbCtx, &model.ChatingRoom{RoomId: roomId, RoomName: roomId})
+		if err != nil {
+			return err
+		}
+		for _, memberId := range []int64{localCtx.AccountId, accountId} {
+			_, err = repo.InsertRoomMember(localCtx.RdbCtx, roomId, memberId, constants.RoomRoleMember)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.ChatingRoom{}, err
+	}
+
+	return repo.GetChatingRoom(localCtx.RdbCtx, roomId)
+}
+
+func GetDirectConversations(localCtx *model.LocalCtx) ([]model.DirectConversation, error) {
+	return repo.FetchDirectConversations(localCtx.RdbCtx, localCtx.AccountId)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+	"chating_service/internal/utils"
+)
+
+var ErrTooManyMentions = errors.New("too many mentions")
+
+// resolveMentions finds the room members named by @userId in the body.
+// Names that are not members of the room are left as plain text.
+// @room and @here are only allowed to room moderators.
+func resolveMentions(localCtx *model.LocalCtx, roomId string, body string) ([]model.Mention, string, error) {
+	mentionAll := ""
+	userIds := []string{}
+	for _, name := range utils.ParseMentions(body) {
+		switch name {
+		case constants.MentionRoom, constants.MentionHere:
+			// @room covers everyone @here would reach
+			if mentionAll != constants.MentionRoom {
+				mentionAll = name
+			}
+		default:
+			userIds = append(userIds, name)
+		}
+	}
+
+	if len(userIds) > constants.MaxMentionsPerMessage {
+		return nil, "", ErrTooManyMentions
+	}
+
+	if mentionAll != "" {
+		role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+		if err != nil {
+			return nil, "", err
+		}
+		if role < constants.RoomRoleModerator {
+			return nil, "", ErrPermissionDenied
+		}
+	}
+
+	mentions, err := repo.FetchMentionableMembers(localCtx.RdbCtx, roomId, userIds)
+	if err != nil {
+		return nil, "", err
+	}
+	return mentions, mentionAll, nil
+}
+
+// GetMentionRecipients returns the accounts a message mentions, except its sender.
+// @room reaches every member of the room, @here the members online in it.
+func GetMentionRecipients(localCtx *model.LocalCtx, message model.Message) ([]int64, error) {
+	accountIds := make([]int64, 0, len(message.Mentions))
+	for _, mention := range message.Mentions {
+		accountIds = append(accountIds, mention.AccountId)
+	}
+
+	switch message.MentionAll {
+	case constants.MentionRoom:
+		memberIds, err := repo.FetchRoomMemberIds(localCtx.RdbCtx, message.RoomId)
+		if err != nil {
+			return nil, err
+		}
+		accountIds = append(accountIds, memberIds...)
+	case constants.MentionHere:
+		presences, err := repo.FetchRoomPresence(message.RoomId, localCtx)
+		if err != nil {
+			return nil, err
+		}
+		for _, presence := range presences {
+			if presence.Status == constants.PresenceOnline {
+				accountIds = append(accountIds, presence.AccountId)
+			}
+		}
+	}
+
+	recipients := make([]int64, 0, len(accountIds))
+	seen := map[int64]bool{message.AccountId: true}
+	for _, accountId := range accountIds {
+		if !seen[accountId] {
+			seen[accountId] = true
+			recipients = append(recipients, accountId)
+		}
+	}
+	return recipients, nil
+}
+
+// attachMentions fills the mentions of the messages. Tombstones mention nobody.
+func attachMentions(localCtx *model.LocalCtx, messages []model.Message) error {
+	messageIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		messageIds = append(messageIds, message.Id)
+	}
+
+	mentions, err := repo.FetchMentions(localCtx.RdbCtx, messageIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Mentions = mentions[messages[i].Id]
+		if messages[i].Deleted {
+			messages[i].Mentions = nil
+			messages[i].MentionAll = ""
+		}
+		if messages[i].Mentions == nil {
+			messages[i].Mentions = []model.Mention{}
+		}
+	}
+	return nil
+}
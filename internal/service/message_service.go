@@ -0,0 +1,368 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+	"chating_service/internal/search"
+	"chating_service/internal/utils"
+)
+
+// SaveMessage records a message sent by the account of localCtx to the room.
+// A ParentId other than 0 stores it as a reply in the thread of that message.
+// The timestamp is always taken from the server clock.
+func SaveMessage(localCtx *model.LocalCtx, roomId string, form model.NewMessageForm) (model.Message, error) {
+	parentId := form.ParentId
+	message := model.Message{
+		RoomId:    roomId,
+		AccountId: localCtx.AccountId,
+		Body:      form.Body,
+		CreatedAt: time.Now(),
+	}
+
+	if strings.TrimSpace(form.Body) == "" && len(form.AttachmentKeys) == 0 {
+		return model.Message{}, ErrEmptyMessage
+	}
+
+	if parentId > 0 {
+		parent, err := getRoomMessage(localCtx, roomId, parentId)
+		if err != nil {
+			return model.Message{}, err
+		}
+		// threads are a single level deep
+		if parent.ParentId != nil {
+			return model.Message{}, ErrInvalidThreadParent
+		}
+		message.ParentId = &parentId
+	}
+
+	var err error
+	message.Mentions, message.MentionAll, err = resolveMentions(localCtx, roomId, form.Body)
+	if err != nil {
+		return model.Message{}, err
+	}
+	message.Attachments, err = resolveAttachments(localCtx, roomId, form.AttachmentKeys)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		seq, err := repo.NextRoomSeq(localCtx.RdbCtx, roomId)
+		if err != nil {
+			return err
+		}
+		message.Seq = seq
+
+		err = repo.InsertMessage(localCtx.RdbCtx, &message)
+		if err != nil {
+			return err
+		}
+		err = repo.InsertMentions(localCtx.RdbCtx, message.Id, message.Mentions)
+		if err != nil {
+			return err
+		}
+		for _, attachment := range message.Attachments {
+			attached, err := repo.AttachToMessage(localCtx.RdbCtx, attachment.ObjectKey, message.Id)
+			if err != nil {
+				return err
+			}
+			// sent by a concurrent message, or listed twice
+			if !attached {
+				return ErrAttachmentNotFound
+			}
+		}
+		if parentId == 0 {
+			return nil
+		}
+		return repo.IncrementReplyCount(localCtx.RdbCtx, parentId, message.CreatedAt)
+	})
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	search.GetSearcher().Index(message)
+
+	err = signAttachments(message.Attachments)
+	if err != nil {
+		return model.Message{}, err
+	}
+	return message, nil
+}
+
+// GetMessages returns one page of the room history older than the cursor.
+// Replies are left out; they are fetched per thread with GetThreadReplies.
+func GetMessages(localCtx *model.LocalCtx, roomId string, cursor string, limit int) (model.MessagePage, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	return getMessagePage(localCtx, roomId, 0, cursor, limit)
+}
+
+// GetThreadReplies returns one page of the replies to a message older than the cursor
+func GetThreadReplies(localCtx *model.LocalCtx, roomId string, parentId int64, cursor string, limit int) (model.MessagePage, error) {
+	_, err := getRoomMessage(localCtx, roomId, parentId)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	return getMessagePage(localCtx, roomId, parentId, cursor, limit)
+}
+
+// GetMessagesAfterSeq returns up to limit messages of the room after the sequence number afterSeq,
+// oldest first, for a client connected to the room that resumes after a reconnect.
+// The bool is true if more messages follow.
+func GetMessagesAfterSeq(localCtx *model.LocalCtx, roomId string, afterSeq int64, limit int) ([]model.Message, bool, error) {
+	messages, err := repo.FetchMessagesAfterSeq(localCtx.RdbCtx, roomId, afterSeq, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	truncated := len(messages) > limit
+	if truncated {
+		messages = messages[:limit]
+	}
+
+	err = attachMentions(localCtx, messages)
+	if err != nil {
+		return nil, false, err
+	}
+	err = attachAttachments(localCtx, messages)
+	if err != nil {
+		return nil, false, err
+	}
+	return messages, truncated, nil
+}
+
+// KeepUnackedMessages remembers the messages a closed connection of the account never acknowledged
+func KeepUnackedMessages(localCtx *model.LocalCtx, roomId string, messageIds []int64) error {
+	return repo.SaveUnackedMessages(roomId, localCtx.AccountId, messageIds, localCtx)
+}
+
+// GetUnackedMessages returns the messages of the room the account did not acknowledge on its
+// previous connections and forgets them. Messages deleted since are left out.
+func GetUnackedMessages(localCtx *model.LocalCtx, roomId string) ([]model.Message, error) {
+	messageIds, err := repo.PopUnackedMessages(roomId, localCtx.AccountId, localCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := repo.FetchMessagesByIds(localCtx.RdbCtx, roomId, messageIds)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]model.Message, 0, len(stored))
+	for _, message := range stored {
+		if !message.Deleted {
+			messages = append(messages, message)
+		}
+	}
+
+	err = attachMentions(localCtx, messages)
+	if err != nil {
+		return nil, err
+	}
+	err = attachAttachments(localCtx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// GetUserIds returns the user id of each account
+func GetUserIds(localCtx *model.LocalCtx, accountIds []int64) (map[int64]string, error) {
+	return repo.FetchUserIds(localCtx.RdbCtx, accountIds)
+}
+
+// GetThreadParticipants returns the accounts that took part in the thread of a message
+func GetThreadParticipants(localCtx *model.LocalCtx, parentId int64) ([]int64, error) {
+	return repo.FetchThreadParticipants(localCtx.RdbCtx, parentId)
+}
+
+func getMessagePage(localCtx *model.LocalCtx, roomId string, parentId int64, cursor string, limit int) (model.MessagePage, error) {
+	beforeId, err := utils.DecodeCursor(cursor)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	// fetch one extra row to find out whether an older page exists
+	messages, err := repo.FetchMessages(localCtx.RdbCtx, roomId, parentId, beforeId, limit+1)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+
+	page := model.MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = utils.EncodeCursor(page.Messages[limit-1].Id)
+	}
+
+	err = attachReactions(localCtx, page.Messages)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+	err = attachMentions(localCtx, page.Messages)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+	err = attachAttachments(localCtx, page.Messages)
+	if err != nil {
+		return model.MessagePage{}, err
+	}
+	return page, nil
+}
+
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrMessageTooLong  = errors.New("message text too long")
+	ErrEmptyMessage    = errors.New("message text is required")
+
+	ErrInvalidThreadParent = errors.New("replies can not start a thread")
+)
+
+// getRoomMessage returns a message that belongs to a room the account of localCtx can access
+// and is not deleted
+func getRoomMessage(localCtx *model.LocalCtx, roomId string, messageId int64) (model.Message, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	message, err := repo.GetMessage(localCtx.RdbCtx, messageId)
+	if err == sql.ErrNoRows {
+		return message, ErrMessageNotFound
+	}
+	if err != nil {
+		return message, err
+	}
+	if message.RoomId != roomId || message.Deleted {
+		return message, ErrMessageNotFound
+	}
+	return message, nil
+}
+
+// EditMessage replaces the body of a message written by the account of localCtx.
+// The previous body is kept in the edit history. Mentions are resolved again
+// but nobody is notified of them a second time.
+func EditMessage(localCtx *model.LocalCtx, roomId string, messageId int64, body string) (model.Message, error) {
+	if strings.TrimSpace(body) == "" {
+		return model.Message{}, ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(body) > constants.MaxMessageTextLength {
+		return model.Message{}, ErrMessageTooLong
+	}
+
+	message, err := getRoomMessage(localCtx, roomId, messageId)
+	if err != nil {
+		return message, err
+	}
+	if message.AccountId != localCtx.AccountId {
+		return message, ErrPermissionDenied
+	}
+
+	mentions, mentionAll, err := resolveMentions(localCtx, roomId, body)
+	if err != nil {
+		return message, err
+	}
+
+	editedAt := time.Now()
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		edit := model.MessageEdit{MessageId: messageId, Body: message.Body, EditedBy: localCtx.AccountId, EditedAt: editedAt}
+		err := repo.InsertMessageEdit(localCtx.RdbCtx, &edit)
+		if err != nil {
+			return err
+		}
+		err = repo.UpdateMessageBody(localCtx.RdbCtx, messageId, body, mentionAll, editedAt)
+		if err != nil {
+			return err
+		}
+		err = repo.DeleteMentions(localCtx.RdbCtx, messageId)
+		if err != nil {
+			return err
+		}
+		return repo.InsertMentions(localCtx.RdbCtx, messageId, mentions)
+	})
+	if err != nil {
+		return message, err
+	}
+
+	message.Body = body
+	message.EditedAt = &editedAt
+	message.Mentions = mentions
+	message.MentionAll = mentionAll
+	search.GetSearcher().Index(message)
+	return message, nil
+}
+
+// DeleteMessage turns a message into a tombstone.
+// Authors may delete their own messages, moderators of the room any message.
+func DeleteMessage(localCtx *model.LocalCtx, roomId string, messageId int64) (model.Message, error) {
+	message, err := getRoomMessage(localCtx, roomId, messageId)
+	if err != nil {
+		return message, err
+	}
+
+	if message.AccountId != localCtx.AccountId {
+		role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+		if err != nil {
+			return message, err
+		}
+		if role < constants.RoomRoleModerator {
+			return message, ErrPermissionDenied
+		}
+	}
+
+	deletedAt := time.Now()
+	err = localCtx.RdbCtx.RunInTxn(func() error {
+		edit := model.MessageEdit{MessageId: messageId, Body: message.Body, EditedBy: localCtx.AccountId, EditedAt: deletedAt}
+		err := repo.InsertMessageEdit(localCtx.RdbCtx, &edit)
+		if err != nil {
+			return err
+		}
+		return repo.DeleteMessage(localCtx.RdbCtx, messageId, localCtx.AccountId, deletedAt)
+	})
+	if err != nil {
+		return message, err
+	}
+
+	message.Body = ""
+	message.Deleted = true
+	search.GetSearcher().Remove(messageId)
+	return message, nil
+}
+
+// GetMessageEdits returns the previous bodies of a message to its author and room moderators
+func GetMessageEdits(localCtx *model.LocalCtx, roomId string, messageId int64) ([]model.MessageEdit, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := repo.GetMessage(localCtx.RdbCtx, messageId)
+	if err == sql.ErrNoRows || (err == nil && message.RoomId != roomId) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if message.AccountId != localCtx.AccountId {
+		role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+		if err != nil {
+			return nil, err
+		}
+		if role < constants.RoomRoleModerator {
+			return nil, ErrPermissionDenied
+		}
+	}
+
+	return repo.FetchMessageEdits(localCtx.RdbCtx, messageId)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+// ConnectPresence records a new connection of the account of localCtx to the room.
+// It returns true if it is the first live connection of the account, i.e. the account came online.
+func ConnectPresence(localCtx *model.LocalCtx, roomId string) (bool, error) {
+	count, err := repo.IncrPresence(roomId, localCtx.AccountId, localCtx)
+	if err != nil {
+		return false, err
+	}
+
+	err = repo.SetPresenceStatus(roomId, localCtx.AccountId, constants.PresenceOnline, constants.PresenceTtl, localCtx)
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+// DisconnectPresence removes a connection of the account of localCtx from the room.
+// It returns true if it was the last live connection of the account, i.e. the account went offline.
+func DisconnectPresence(localCtx *model.LocalCtx, roomId string) (bool, error) {
+	count, err := repo.DecrPresence(roomId, localCtx.AccountId, localCtx)
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// UpdatePresence refreshes the presence ttl with the given status.
+// It returns true if the status differs from the stored one.
+func UpdatePresence(localCtx *model.LocalCtx, roomId string, status string) (bool, error) {
+	previous := repo.GetPresenceStatus(roomId, localCtx.AccountId, localCtx)
+
+	err := repo.SetPresenceStatus(roomId, localCtx.AccountId, status, constants.PresenceTtl, localCtx)
+	if err != nil {
+		return false, err
+	}
+	return previous != status, nil
+}
+
+// GetRoomPresence returns the online and away members of a room the account of localCtx can access
+func GetRoomPresence(localCtx *model.LocalCtx, roomId string) ([]model.Presence, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	return repo.FetchRoomPresence(roomId, localCtx)
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"strconv"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/notifier"
+	"chating_service/internal/repo"
+)
+
+// GetOfflineNotifications returns the push notifications of a message for the members of its
+// room without a live connection to any room. Mentioned members get a mention notification.
+func GetOfflineNotifications(localCtx *model.LocalCtx, sender model.Sender, message model.Message, mentioned []int64) ([]notifier.Notification, error) {
+	memberIds, err := repo.FetchRoomMemberIds(localCtx.RdbCtx, message.RoomId)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]int64, 0, len(memberIds))
+	for _, accountId := range memberIds {
+		if accountId != message.AccountId {
+			recipients = append(recipients, accountId)
+		}
+	}
+
+	connected, err := repo.FetchConnectedAccounts(recipients, localCtx)
+	if err != nil {
+		return nil, err
+	}
+	offline := make([]int64, 0, len(recipients))
+	for _, accountId := range recipients {
+		if !connected[accountId] {
+			offline = append(offline, accountId)
+		}
+	}
+
+	pushTokens, err := repo.FetchPushTokens(localCtx.RdbCtx, offline)
+	if err != nil {
+		return nil, err
+	}
+
+	isMentioned := make(map[int64]bool, len(mentioned))
+	for _, accountId := range mentioned {
+		isMentioned[accountId] = true
+	}
+
+	notifications := make([]notifier.Notification, 0, len(pushTokens))
+	for _, accountId := range offline {
+		pushToken, ok := pushTokens[accountId]
+		if !ok {
+			continue
+		}
+
+		notification := notifier.Notification{
+			AccountId: accountId,
+			PushToken: pushToken,
+			Title:     sender.UserId,
+			Body:      pushPreview(message),
+			Data: map[string]string{
+				"type":      model.EnvelopeTypeMessage,
+				"roomId":    message.RoomId,
+				"messageId": strconv.FormatInt(message.Id, 10),
+			},
+		}
+		if isMentioned[accountId] {
+			notification.Title = sender.UserId + " mentioned you"
+			notification.Data["type"] = model.EnvelopeTypeMention
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+// pushPreview shortens a message body to the length shown in a push notification
+func pushPreview(message model.Message) string {
+	body := message.Body
+	if body == "" && len(message.Attachments) > 0 {
+		return "sent an attachment"
+	}
+
+	runes := []rune(body)
+	if len(runes) <= constants.PushPreviewLength {
+		return body
+	}
+	return string(runes[:constants.PushPreviewLength]) + "…"
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"strconv"
+	"time"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+var (
+	connectionFrameLimit = model.RateLimit{Burst: constants.ConnectionFrameBurst, Refill: constants.ConnectionFrameRefill}
+	typingFrameLimit     = model.RateLimit{Burst: constants.TypingFrameBurst, Refill: constants.TypingFrameRefill}
+	accountMessageLimit  = model.RateLimit{Burst: constants.AccountMessageBurst, Refill: constants.AccountMessageRefill}
+	roomMessageLimit     = model.RateLimit{Burst: constants.RoomMessageBurst, Refill: constants.RoomMessageRefill}
+	loginLimit           = model.RateLimit{Burst: constants.LoginBurst, Refill: constants.LoginRefill}
+	loginUserLimit       = model.RateLimit{Burst: constants.LoginUserBurst, Refill: constants.LoginUserRefill}
+	refreshTokenLimit    = model.RateLimit{Burst: constants.RefreshTokenBurst, Refill: constants.RefreshTokenRefill}
+)
+
+// AllowFrame takes a token for a frame of the connection and, for chat messages, for the
+// account of localCtx and the room. It returns 0 when the frame may go on, or how long the
+// client has to wait.
+func AllowFrame(localCtx *model.LocalCtx, connId string, roomId string, message bool) (time.Duration, error) {
+	retryAfter, err := repo.TakeToken("conn", connId, connectionFrameLimit, localCtx)
+	if err != nil || retryAfter > 0 || !message {
+		return retryAfter, err
+	}
+
+	retryAfter, err = repo.TakeToken("account", strconv.FormatInt(localCtx.AccountId, 10), accountMessageLimit, localCtx)
+	if err != nil || retryAfter > 0 {
+		return retryAfter, err
+	}
+	return repo.TakeToken("room", roomId, roomMessageLimit, localCtx)
+}
+
+// AllowTypingFrame takes a token for a typing indicator of the connection, apart from its other frames
+func AllowTypingFrame(localCtx *model.LocalCtx, connId string) (time.Duration, error) {
+	return repo.TakeToken("typing", connId, typingFrameLimit, localCtx)
+}
+
+// RecordRateLimitStrike counts a limited frame of the account of localCtx and returns whether
+// the account went over the strikes allowed in the window
+func RecordRateLimitStrike(localCtx *model.LocalCtx) (bool, error) {
+	strikes, err := repo.IncrRateLimitStrike(localCtx.AccountId, localCtx)
+	if err != nil {
+		return false, err
+	}
+	return strikes > constants.MaxRateLimitStrikes, nil
+}
+
+// AllowLogin takes a token for a login attempt from the client address
+func AllowLogin(localCtx *model.LocalCtx, clientIp string) (time.Duration, error) {
+	return repo.TakeToken("login", clientIp, loginLimit, localCtx)
+}
+
+// AllowLoginUser takes a token for a login attempt to the user id, wherever it comes from
+func AllowLoginUser(localCtx *model.LocalCtx, userId string) (time.Duration, error) {
+	return repo.TakeToken("login_user", userId, loginUserLimit, localCtx)
+}
+
+// AllowRefreshToken takes a token for a token refresh from the client address
+func AllowRefreshToken(localCtx *model.LocalCtx, clientIp string) (time.Duration, error) {
+	return repo.TakeToken("refresh", clientIp, refreshTokenLimit, localCtx)
+}
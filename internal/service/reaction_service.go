@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+var ErrInvalidEmoji = errors.New("invalid emoji")
+
+func validateEmoji(emoji string) error {
+	if emoji == "" || len(emoji) > constants.MaxEmojiLength || strings.ContainsAny(emoji, " \t\r\n") {
+		return ErrInvalidEmoji
+	}
+	return nil
+}
+
+// AddReaction adds an emoji of the account of localCtx to a message.
+// It returns the change to broadcast, or nil if the account had already reacted with the emoji.
+func AddReaction(localCtx *model.LocalCtx, roomId string, messageId int64, emoji string) (*model.ReactionPayload, error) {
+	err := validateEmoji(emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = getRoomMessage(localCtx, roomId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	inserted, err := repo.InsertReaction(localCtx.RdbCtx, messageId, localCtx.AccountId, emoji)
+	if err != nil || !inserted {
+		return nil, err
+	}
+	return reactionChange(localCtx, messageId, emoji)
+}
+
+// RemoveReaction removes an emoji of the account of localCtx from a message.
+// It returns the change to broadcast, or nil if the account had not reacted with the emoji.
+func RemoveReaction(localCtx *model.LocalCtx, roomId string, messageId int64, emoji string) (*model.ReactionPayload, error) {
+	_, err := getRoomMessage(localCtx, roomId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := repo.DeleteReaction(localCtx.RdbCtx, messageId, localCtx.AccountId, emoji)
+	if err != nil || !deleted {
+		return nil, err
+	}
+	return reactionChange(localCtx, messageId, emoji)
+}
+
+func reactionChange(localCtx *model.LocalCtx, messageId int64, emoji string) (*model.ReactionPayload, error) {
+	count, err := repo.CountReaction(localCtx.RdbCtx, messageId, emoji)
+	if err != nil {
+		return nil, err
+	}
+	return &model.ReactionPayload{Emoji: emoji, AccountId: localCtx.AccountId, Count: count}, nil
+}
+
+// attachReactions fills the reaction aggregates of the messages
+func attachReactions(localCtx *model.LocalCtx, messages []model.Message) error {
+	messageIds := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		messageIds = append(messageIds, message.Id)
+	}
+
+	reactions, err := repo.FetchReactions(localCtx.RdbCtx, messageIds)
+	if err != nil {
+		return err
+	}
+
+	for i := range messages {
+		messages[i].Reactions = reactions[messages[i].Id]
+		if messages[i].Reactions == nil {
+			messages[i].Reactions = []model.Reaction{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package service
+
+import (
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+// MarkRead moves the read marker of the account of localCtx in the room to messageId.
+// It returns false if the marker did not move, in which case nothing needs to be broadcast.
+func MarkRead(localCtx *model.LocalCtx, roomId string, messageId int64) (bool, error) {
+	return repo.UpdateLastReadMessage(localCtx.RdbCtx, roomId, localCtx.AccountId, messageId)
+}
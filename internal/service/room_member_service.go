@@ -0,0 +1,221 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+var (
+	ErrRoomNotFound     = errors.New("room not found")
+	ErrNotRoomMember    = errors.New("not a member of the room")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrAlreadyMember    = errors.New("already a member of the room")
+	ErrAccountNotFound  = errors.New("account not found")
+	ErrInvalidRole      = errors.New("invalid room role")
+)
+
+// getActiveRoom returns the room if it exists and is not archived
+func getActiveRoom(localCtx *model.LocalCtx, roomId string) (model.ChatingRoom, error) {
+	chatingRoom, err := repo.GetChatingRoom(localCtx.RdbCtx, roomId)
+	if err == sql.ErrNoRows {
+		return chatingRoom, ErrRoomNotFound
+	}
+	if err != nil {
+		return chatingRoom, err
+	}
+	if !chatingRoom.IsUsed {
+		return chatingRoom, ErrRoomNotFound
+	}
+	return chatingRoom, nil
+}
+
+// CheckRoomAccess returns nil if the account of localCtx may read the room.
+// Public rooms are open to everyone, private rooms only to their members.
+func CheckRoomAccess(localCtx *model.LocalCtx, roomId string) error {
+	chatingRoom, err := getActiveRoom(localCtx, roomId)
+	if err != nil {
+		return err
+	}
+	if !chatingRoom.IsPrivate {
+		return nil
+	}
+
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return err
+	}
+	if role == 0 {
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+// JoinRoom adds the account of localCtx to a public room. Private rooms need an invitation.
+func JoinRoom(localCtx *model.LocalCtx, roomId string) error {
+	chatingRoom, err := getActiveRoom(localCtx, roomId)
+	if err != nil {
+		return err
+	}
+	if chatingRoom.IsPrivate {
+		return ErrPermissionDenied
+	}
+
+	inserted, err := repo.InsertRoomMember(localCtx.RdbCtx, roomId, localCtx.AccountId, constants.RoomRoleMember)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return ErrAlreadyMember
+	}
+	return nil
+}
+
+// LeaveRoom removes the account of localCtx from the room.
+// The owner cannot leave its room and nobody can leave a direct message room.
+func LeaveRoom(localCtx *model.LocalCtx, roomId string) error {
+	chatingRoom, err := repo.GetChatingRoom(localCtx.RdbCtx, roomId)
+	if err == sql.ErrNoRows {
+		return ErrRoomNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if chatingRoom.IsDirect {
+		return ErrPermissionDenied
+	}
+
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return err
+	}
+	if role == 0 {
+		return ErrNotRoomMember
+	}
+	if role == constants.RoomRoleOwner {
+		return ErrPermissionDenied
+	}
+
+	return repo.DeleteRoomMember(localCtx.RdbCtx, roomId, localCtx.AccountId)
+}
+
+// InviteMember adds another account to the room.
+// Any member may invite to a public room, only moderators may invite to a private room.
+// Direct message rooms always keep their two members.
+func InviteMember(localCtx *model.LocalCtx, roomId string, accountId int64) error {
+	chatingRoom, err := getActiveRoom(localCtx, roomId)
+	if err != nil {
+		return err
+	}
+	if chatingRoom.IsDirect {
+		return ErrPermissionDenied
+	}
+
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return err
+	}
+	if role == 0 {
+		return ErrNotRoomMember
+	}
+	if chatingRoom.IsPrivate && role < constants.RoomRoleModerator {
+		return ErrPermissionDenied
+	}
+
+	_, err = repo.GetUserAccountByAccountId(localCtx.RdbCtx, accountId)
+	if err == sql.ErrNoRows {
+		return ErrAccountNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	inserted, err := repo.InsertRoomMember(localCtx.RdbCtx, roomId, accountId, constants.RoomRoleMember)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return ErrAlreadyMember
+	}
+	return nil
+}
+
+// KickMember removes another account from the room.
+// Only moderators may kick, and only members with a lower role than their own.
+func KickMember(localCtx *model.LocalCtx, roomId string, accountId int64) error {
+	role, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return err
+	}
+	if role < constants.RoomRoleModerator {
+		return ErrPermissionDenied
+	}
+
+	targetRole, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, accountId)
+	if err != nil {
+		return err
+	}
+	if targetRole == 0 {
+		return ErrNotRoomMember
+	}
+	if targetRole >= role {
+		return ErrPermissionDenied
+	}
+
+	return repo.DeleteRoomMember(localCtx.RdbCtx, roomId, accountId)
+}
+
+// ChangeMemberRole makes another member of the room a moderator or a plain member.
+// Only the owner may change roles, and ownership itself cannot be given away.
+func ChangeMemberRole(localCtx *model.LocalCtx, roomId string, accountId int64, role int) error {
+	if role != constants.RoomRoleMember && role != constants.RoomRoleModerator {
+		return ErrInvalidRole
+	}
+	chatingRoom, err := getActiveRoom(localCtx, roomId)
+	if err != nil {
+		return err
+	}
+	if chatingRoom.IsDirect {
+		return ErrPermissionDenied
+	}
+
+	callerRole, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, localCtx.AccountId)
+	if err != nil {
+		return err
+	}
+	if callerRole != constants.RoomRoleOwner {
+		return ErrPermissionDenied
+	}
+
+	targetRole, err := repo.GetRoomMemberRole(localCtx.RdbCtx, roomId, accountId)
+	if err != nil {
+		return err
+	}
+	if targetRole == 0 {
+		return ErrNotRoomMember
+	}
+	if targetRole == constants.RoomRoleOwner {
+		return ErrPermissionDenied
+	}
+
+	updated, err := repo.UpdateRoomMemberRole(localCtx.RdbCtx, roomId, accountId, role)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrNotRoomMember
+	}
+	return nil
+}
+
+// GetRoomMembers returns the members of a room the account of localCtx can access
+func GetRoomMembers(localCtx *model.LocalCtx, roomId string) ([]model.RoomMember, error) {
+	err := CheckRoomAccess(localCtx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	return repo.FetchRoomMembers(localCtx.RdbCtx, roomId)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+	"chating_service/internal/search"
+	"chating_service/internal/utils"
+)
+
+var ErrEmptySearch = errors.New("search text is required")
+
+// SearchMessages searches the messages of the rooms the account of localCtx is a member of
+func SearchMessages(localCtx *model.LocalCtx, form model.SearchForm) (model.SearchPage, error) {
+	terms := search.Terms(form.Text)
+	if len(terms) == 0 {
+		return model.SearchPage{}, ErrEmptySearch
+	}
+
+	beforeId, err := utils.DecodeCursor(form.Cursor)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+
+	roomIds, err := repo.FetchMemberRoomIds(localCtx.RdbCtx, localCtx.AccountId)
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+	if form.RoomId != "" {
+		if !containsRoom(roomIds, form.RoomId) {
+			return model.SearchPage{}, ErrNotRoomMember
+		}
+		roomIds = []string{form.RoomId}
+	}
+
+	// fetch one extra hit to find out whether an older page exists
+	hits, err := search.GetSearcher().Search(localCtx, model.SearchQuery{
+		Terms:    terms,
+		RoomIds:  roomIds,
+		From:     form.From,
+		Before:   form.Before,
+		BeforeId: beforeId,
+		Limit:    form.Limit + 1,
+	})
+	if err != nil {
+		return model.SearchPage{}, err
+	}
+
+	page := model.SearchPage{Results: hits}
+	if len(hits) > form.Limit {
+		page.Results = hits[:form.Limit]
+		page.NextCursor = utils.EncodeCursor(page.Results[form.Limit-1].MessageId)
+	}
+	for i := range page.Results {
+		page.Results[i].Snippet = search.Highlight(page.Results[i].Body, terms)
+	}
+	return page, nil
+}
+
+func containsRoom(roomIds []string, roomId string) bool {
+	for _, id := range roomIds {
+		if id == roomId {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package service
+
+import (
+	"chating_service/internal/constants"
+	"chating_service/internal/model"
+	"chating_service/internal/repo"
+)
+
+// AllowTyping returns whether a typing event of the account of localCtx should be relayed.
+// Start events are throttled per user and room; a stop event always passes and resets the throttle.
+func AllowTyping(localCtx *model.LocalCtx, roomId string, typing bool) (bool, error) {
+	if !typing {
+		return true, repo.ReleaseTypingSlot(roomId, localCtx.AccountId, localCtx)
+	}
+	return repo.AcquireTypingSlot(roomId, localCtx.AccountId, constants.TypingThrottle, localCtx)
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"chating_service/internal/config"
+)
+
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// LocalStorageRoute is the path the server serves local storage objects under
+const LocalStorageRoute = "/files/"
+
+// LocalStorage keeps objects on the local disk. Its signed urls point at this server,
+// which checks the signature before reading or writing the object.
+type LocalStorage struct {
+	root    string
+	baseUrl string
+	signKey []byte
+}
+
+func NewLocalStorage(localConfig config.LocalStorageConfig) (*LocalStorage, error) {
+	root := localConfig.Path
+	if root == "" {
+		root = "./data/attachments"
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	signKey := []byte(localConfig.SignKey)
+	if len(signKey) == 0 {
+		// urls signed before a restart stop working, set storage.local.sign-key to keep them
+		log.Warn().Msg("storage.local.sign-key is not set, using a random key")
+		signKey = make([]byte, 32)
+		if _, err := rand.Read(signKey); err != nil {
+			return nil, err
+		}
+	}
+
+	baseUrl := localConfig.BaseUrl
+	if baseUrl == "" {
+		baseUrl = "http://localhost:8080"
+	}
+
+	return &LocalStorage{root: root, baseUrl: strings.TrimRight(baseUrl, "/"), signKey: signKey}, nil
+}
+
+func (s *LocalStorage) SignUploadUrl(objectKey string, contentType string, expire time.Duration) (string, error) {
+	return s.signUrl("PUT", objectKey, time.Now().Add(expire)), nil
+}
+
+func (s *LocalStorage) SignDownloadUrl(objectKey string, expire time.Duration) (string, error) {
+	return s.signUrl("GET", objectKey, time.Now().Add(expire)), nil
+}
+
+func (s *LocalStorage) signUrl(method string, objectKey string, expireAt time.Time) string {
+	expires := strconv.FormatInt(expireAt.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(method, objectKey, expires))
+	return s.baseUrl + LocalStorageRoute + objectKey + "?" + query.Encode()
+}
+
+func (s *LocalStorage) signature(method string, objectKey string, expires string) string {
+	mac := hmac.New(sha256.New, s.signKey)
+	mac.Write([]byte(method + "\n" + objectKey + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a request made with a url from SignUploadUrl or SignDownloadUrl
+func (s *LocalStorage) VerifySignature(method string, objectKey string, expires string, signature string) error {
+	expireAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expireAt {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(method, objectKey, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// objectPath returns the file of an object, refusing keys that leave the storage root
+func (s *LocalStorage) objectPath(objectKey string) (string, error) {
+	cleaned := filepath.Clean("/" + objectKey)
+	if cleaned == "/" || cleaned != "/"+objectKey {
+		return "", ErrObjectNotFound
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Save writes an uploaded object. The object is replaced only once it is completely written.
+func (s *LocalStorage) Save(objectKey string, content io.Reader) error {
+	objectPath, err := s.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), objectPath)
+}
+
+// Open returns the content of an object
+func (s *LocalStorage) Open(objectKey string) (*os.File, error) {
+	objectPath, err := s.objectPath(objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, objectKey string) error {
+	objectPath, err := s.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(objectPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	file, err := s.Open(objectKey)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return ObjectInfo{Size: size, Checksum: hex.EncodeToString(hash.Sum(nil))}, nil
+}
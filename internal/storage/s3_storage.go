@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"chating_service/internal/config"
+)
+
+const (
+	s3Algorithm   = "AWS4-HMAC-SHA256"
+	s3DateFormat  = "20060102T150405Z"
+	s3MaxExpire   = 7 * 24 * time.Hour
+	s3StatTimeout = 10 * time.Second
+)
+
+// S3Storage keeps objects in an S3 compatible bucket.
+// Urls are presigned with signature version 4, so no SDK is needed.
+type S3Storage struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	accessKey    string
+	secretKey    string
+	usePathStyle bool
+	client       *http.Client
+}
+
+func NewS3Storage(s3Config config.S3StorageConfig) (*S3Storage, error) {
+	if s3Config.Bucket == "" || s3Config.AccessKey == "" || s3Config.SecretKey == "" {
+		return nil, errors.New("storage.s3 needs bucket, access-key and secret-key")
+	}
+
+	endpoint := s3Config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3.amazonaws.com"
+	}
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	region := s3Config.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Storage{
+		endpoint:     endpointUrl,
+		region:       region,
+		bucket:       s3Config.Bucket,
+		accessKey:    s3Config.AccessKey,
+		secretKey:    s3Config.SecretKey,
+		usePathStyle: s3Config.UsePathStyle,
+		client:       &http.Client{Timeout: s3StatTimeout},
+	}, nil
+}
+
+func (s *S3Storage) SignUploadUrl(objectKey string, contentType string, expire time.Duration) (string, error) {
+	return s.presign(http.MethodPut, objectKey, expire, time.Now())
+}
+
+func (s *S3Storage) SignDownloadUrl(objectKey string, expire time.Duration) (string, error) {
+	return s.presign(http.MethodGet, objectKey, expire, time.Now())
+}
+
+// Stat reads the size and ETag of the object. The ETag of an object uploaded
+// with a single PUT is the md5 of its content.
+func (s *S3Storage) Stat(ctx context.Context, objectKey string) (ObjectInfo, error) {
+	signedUrl, err := s.presign(http.MethodHead, objectKey, time.Minute, time.Now())
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, signedUrl, nil)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ObjectInfo{}, ErrObjectNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		return ObjectInfo{}, fmt.Errorf("s3 head object returned %d", response.StatusCode)
+	}
+
+	return ObjectInfo{
+		Size:     response.ContentLength,
+		Checksum: strings.Trim(response.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+// Delete removes the object. S3 answers 204 whether the object existed or not.
+func (s *S3Storage) Delete(ctx context.Context, objectKey string) error {
+	signedUrl, err := s.presign(http.MethodDelete, objectKey, time.Minute, time.Now())
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, signedUrl, nil)
+	if err != nil {
+		return err
+	}
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return fmt.Errorf("s3 delete object returned %d", response.StatusCode)
+}
+
+// objectUrl returns the host and the path of an object
+func (s *S3Storage) objectUrl(objectKey string) (string, string) {
+	if s.usePathStyle {
+		return s.endpoint.Host, "/" + s.bucket + "/" + objectKey
+	}
+	return s.bucket + "." + s.endpoint.Host, "/" + objectKey
+}
+
+// presign builds a url that allows method on the object until expire has passed
+func (s *S3Storage) presign(method string, objectKey string, expire time.Duration, now time.Time) (string, error) {
+	if expire <= 0 || expire > s3MaxExpire {
+		return "", errors.New("s3 url expiry must be between 1 second and 7 days")
+	}
+
+	now = now.UTC()
+	amzDate := now.Format(s3DateFormat)
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	host, path := s.objectUrl(objectKey)
+	canonicalPath := s3Escape(path, false)
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     s3Algorithm,
+		"X-Amz-Credential":    s.accessKey + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.FormatInt(int64(expire/time.Second), 10),
+		"X-Amz-SignedHeaders": "host",
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath,
+		canonicalQuery,
+		"host:" + host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(s3Hash([]byte(canonicalRequest))),
+	}, "\n")
+
+	signingKey := s3Hmac([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	signingKey = s3Hmac(signingKey, s.region)
+	signingKey = s3Hmac(signingKey, "s3")
+	signingKey = s3Hmac(signingKey, "aws4_request")
+	signature := hex.EncodeToString(s3Hmac(signingKey, stringToSign))
+
+	return s.endpoint.Scheme + "://" + host + canonicalPath + "?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+func s3CanonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, s3Escape(key, true)+"="+s3Escape(query[key], true))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent encodes everything but the unreserved characters, as signature version 4 expects.
+// Slashes are kept in paths.
+func s3Escape(value string, escapeSlash bool) string {
+	var escaped strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			escaped.WriteByte(b)
+		case b == '/' && !escapeSlash:
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func s3Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+func s3Hmac(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
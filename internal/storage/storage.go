@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"chating_service/internal/config"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object. Checksum is the hex encoded md5 of its content.
+type ObjectInfo struct {
+	Size     int64
+	Checksum string
+}
+
+// Storage keeps attachment objects. Clients upload and download them directly
+// through the signed urls, the server only checks what was uploaded.
+type Storage interface {
+	SignUploadUrl(objectKey string, contentType string, expire time.Duration) (string, error)
+	SignDownloadUrl(objectKey string, expire time.Duration) (string, error)
+	Stat(ctx context.Context, objectKey string) (ObjectInfo, error)
+	// Delete removes the object, an object that does not exist is not an error
+	Delete(ctx context.Context, objectKey string) error
+}
+
+var storage Storage
+
+// InitStorage creates the storage selected by the config
+func InitStorage(storageConfig config.StorageConfig) error {
+	switch storageConfig.Driver {
+	case "", "local":
+		localStorage, err := NewLocalStorage(storageConfig.Local)
+		if err != nil {
+			return err
+		}
+		storage = localStorage
+	case "s3":
+		s3Storage, err := NewS3Storage(storageConfig.S3)
+		if err != nil {
+			return err
+		}
+		storage = s3Storage
+	default:
+		return errors.New("unknown storage driver " + storageConfig.Driver)
+	}
+	return nil
+}
+
+func GetStorage() Storage {
+	return storage
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -80,3 +82,21 @@ func IsInvalidPassword(password, hashedPassword string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err != nil
 }
+
+// NewRoomId returns a random id for a new chating room
+func NewRoomId() (string, error) {
+	return newRandomId()
+}
+
+// NewObjectId returns a random id that makes storage object keys unguessable
+func NewObjectId() (string, error) {
+	return newRandomId()
+}
+
+func newRandomId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
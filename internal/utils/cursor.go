@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const cursorPrefix = "m:"
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor converts a message id into an opaque pagination cursor
+func EncodeCursor(messageId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(messageId, 10)))
+}
+
+// DecodeCursor returns the message id of a cursor made by EncodeCursor.
+// An empty cursor means "from the latest message" and decodes to 0.
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+
+	messageId, err := strconv.ParseInt(strings.TrimPrefix(string(decoded), cursorPrefix), 10, 64)
+	if err != nil || messageId <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return messageId, nil
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// a mention starts the body or follows a character that can not be part of a user id
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.\-])@([\w.\-]+)`)
+
+// ParseMentions returns the distinct names of the @name tokens in the body, in order.
+// Trailing dots and hyphens are treated as punctuation, e.g. "thanks @alice."
+func ParseMentions(body string) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,17 @@
+CREATE TABLE ATTACHMENT
+(
+    id           BIGINT       NOT NULL AUTO_INCREMENT,
+    object_key   VARCHAR(255) NOT NULL,
+    room_id      VARCHAR(64)  NOT NULL,
+    account_id   BIGINT       NOT NULL,
+    file_name    VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size         BIGINT       NOT NULL,
+    checksum     CHAR(32)     NOT NULL,
+    message_id   BIGINT       NULL,
+    created_at   DATETIME(3)  NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uk_attachment_object_key (object_key),
+    INDEX idx_attachment_message_id (message_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,7 @@
+ALTER TABLE CHATING_ROOM
+    ADD COLUMN owner_id    BIGINT       NOT NULL DEFAULT 0,
+    ADD COLUMN description VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN created_at  DATETIME(3)  NULL,
+    ADD COLUMN created_by  VARCHAR(64)  NULL,
+    ADD COLUMN updated_at  DATETIME(3)  NULL,
+    ADD COLUMN updated_by  VARCHAR(64)  NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE CHATING_ROOM
+    ADD COLUMN is_direct TINYINT(1) NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS MESSAGE
+(
+    id         BIGINT       NOT NULL AUTO_INCREMENT,
+    room_id    VARCHAR(64)  NOT NULL,
+    account_id BIGINT       NOT NULL,
+    body       TEXT         NOT NULL,
+    created_at DATETIME(3)  NOT NULL,
+    created_by VARCHAR(64)  NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_message_room_id (room_id, id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,17 @@
+ALTER TABLE MESSAGE
+    ADD COLUMN updated_at DATETIME(3) NULL,
+    ADD COLUMN deleted_at DATETIME(3) NULL,
+    ADD COLUMN deleted_by BIGINT      NULL;
+
+-- every previous body of an edited or deleted message, kept for audit
+CREATE TABLE IF NOT EXISTS MESSAGE_EDIT_HISTORY
+(
+    id         BIGINT      NOT NULL AUTO_INCREMENT,
+    message_id BIGINT      NOT NULL,
+    body       TEXT        NOT NULL,
+    edited_by  BIGINT      NOT NULL,
+    edited_at  DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX idx_message_edit_history_message_id (message_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,11 @@
+ALTER TABLE MESSAGE
+    ADD COLUMN mention_all VARCHAR(10) NULL;
+
+CREATE TABLE MESSAGE_MENTION
+(
+    message_id BIGINT NOT NULL,
+    account_id BIGINT NOT NULL,
+    PRIMARY KEY (message_id, account_id),
+    INDEX idx_message_mention_account_id (account_id, message_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
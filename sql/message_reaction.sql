@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS MESSAGE_REACTION
+(
+    message_id BIGINT      NOT NULL,
+    account_id BIGINT      NOT NULL,
+    emoji      VARCHAR(32) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (message_id, account_id, emoji)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4
+  COLLATE = utf8mb4_bin;
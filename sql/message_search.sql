@@ -0,0 +1,3 @@
+-- ngram keeps search working for languages without spaces between words
+ALTER TABLE MESSAGE
+    ADD FULLTEXT INDEX ft_message_body (body) WITH PARSER ngram;
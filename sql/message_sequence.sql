@@ -0,0 +1,17 @@
+ALTER TABLE CHATING_ROOM
+    ADD COLUMN last_seq BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE MESSAGE
+    ADD COLUMN seq BIGINT NULL;
+
+-- number the existing messages of every room in id order
+UPDATE MESSAGE m
+    JOIN (SELECT id, ROW_NUMBER() OVER (PARTITION BY room_id ORDER BY id) AS seq FROM MESSAGE) s
+    ON s.id = m.id
+SET m.seq = s.seq;
+
+UPDATE CHATING_ROOM r
+SET r.last_seq = (SELECT IFNULL(MAX(m.seq), 0) FROM MESSAGE m WHERE m.room_id = r.id);
+
+ALTER TABLE MESSAGE
+    ADD UNIQUE INDEX uk_message_room_seq (room_id, seq);
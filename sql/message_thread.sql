@@ -0,0 +1,5 @@
+ALTER TABLE MESSAGE
+    ADD COLUMN parent_id     BIGINT      NULL,
+    ADD COLUMN reply_count   INT         NOT NULL DEFAULT 0,
+    ADD COLUMN last_reply_at DATETIME(3) NULL,
+    ADD INDEX idx_message_parent_id (parent_id, id);
@@ -0,0 +1,2 @@
+ALTER TABLE ROOM_MEMBER
+    ADD COLUMN last_read_message_id BIGINT NOT NULL DEFAULT 0;
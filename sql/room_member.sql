@@ -0,0 +1,14 @@
+ALTER TABLE CHATING_ROOM
+    ADD COLUMN is_private TINYINT(1) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS ROOM_MEMBER
+(
+    room_id    VARCHAR(64) NOT NULL,
+    account_id BIGINT      NOT NULL,
+    role       TINYINT     NOT NULL DEFAULT 1, -- 1: member, 2: moderator, 3: owner
+    created_at DATETIME(3) NOT NULL,
+    created_by VARCHAR(64) NOT NULL,
+    PRIMARY KEY (room_id, account_id),
+    INDEX idx_room_member_account_id (account_id)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;